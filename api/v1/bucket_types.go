@@ -5,6 +5,7 @@
 package v1
 
 import (
+	"k8s.io/apimachinery/pkg/api/resource"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -14,7 +15,102 @@ type BucketSpec struct {
 	// +kubebuilder:validation:Pattern:=`^[a-z0-9]([a-z0-9\.-]){1,61}[a-z0-9]$`
 	Name string `json:"name"`
 	// +kubebuilder:validation:Optional
-	Quota uint64 `json:"quota,omitempty"`
+	Quota *resource.Quantity `json:"quota,omitempty"`
+	// Hard quotas are enforced by MinIO, soft quotas are only monitored by the operator
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=hard;soft
+	// +kubebuilder:default:=hard
+	QuotaType string `json:"quotaType,omitempty"`
+	// Percentage of the quota above which warning events are emitted
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	// +kubebuilder:validation:Maximum=100
+	QuotaAlertThreshold int32 `json:"quotaAlertThreshold,omitempty"`
+	// +kubebuilder:validation:Optional
+	OnDelete *BucketOnDelete `json:"onDelete,omitempty"`
+	// Refuse to delete the bucket unless the deletion is confirmed through the ConfirmDeletionAnnotation
+	// +kubebuilder:validation:Optional
+	DeletionProtection bool `json:"deletionProtection,omitempty"`
+}
+
+// ConfirmDeletionAnnotation confirms the deletion of a protected bucket when set to the bucket's name
+const ConfirmDeletionAnnotation = "minio.scc-digitalhub.github.io/confirm-deletion"
+
+// BucketOnDelete defines what happens to a bucket's contents when it is deleted
+type BucketOnDelete struct {
+	// Copy the bucket's contents to an archive bucket before deleting it
+	// +kubebuilder:validation:Optional
+	ArchiveTo *BucketArchiveTarget `json:"archiveTo,omitempty"`
+}
+
+// BucketArchiveTarget defines where a bucket's contents are archived
+type BucketArchiveTarget struct {
+	// Name of the archive bucket, created if missing
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern:=`^[a-z0-9]([a-z0-9\.-]){1,61}[a-z0-9]$`
+	Bucket string `json:"bucket"`
+	// Prefix of the archived objects, defaults to the name of the bucket. Each deletion is archived under its own timestamp.
+	// +kubebuilder:validation:Optional
+	Prefix string `json:"prefix,omitempty"`
+	// Endpoint of the MinIO instance holding the archive bucket, defaults to the operator's
+	// +kubebuilder:validation:Optional
+	Endpoint string `json:"endpoint,omitempty"`
+	// +kubebuilder:validation:Optional
+	UseSSL bool `json:"useSSL,omitempty"`
+	// Secret in the same namespace with the AWS_ACCESS_KEY_ID and AWS_SECRET_ACCESS_KEY of the archive's instance, required with endpoint
+	// +kubebuilder:validation:Optional
+	CredentialsSecret string `json:"credentialsSecret,omitempty"`
+	// Days after which archived objects expire, never when not set
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Minimum=1
+	RetentionDays int32 `json:"retentionDays,omitempty"`
+}
+
+// BucketUsage reports the data stored in a bucket
+type BucketUsage struct {
+	Size     *resource.Quantity `json:"size,omitempty"`
+	Objects  uint64             `json:"objects"`
+	Versions uint64             `json:"versions"`
+	// Percentage of the quota in use, set when the bucket has a quota
+	QuotaUtilization *int32 `json:"quotaUtilization,omitempty"`
+	// Time MinIO last updated its usage data
+	LastUpdate *metav1.Time `json:"lastUpdate,omitempty"`
+}
+
+// BucketPurge reports the progress of emptying a bucket before it is deleted
+type BucketPurge struct {
+	// Either Running, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+	// Objects and versions deleted so far
+	Deleted int64 `json:"deleted"`
+	// Objects and versions left, estimated from MinIO's usage data
+	Remaining *int64 `json:"remaining,omitempty"`
+	// Objects and versions that could not be deleted
+	Errors int64 `json:"errors,omitempty"`
+	// Last error encountered, if any
+	LastError string `json:"lastError,omitempty"`
+	// Time the purge started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+}
+
+// BucketArchive reports the progress of archiving a bucket before it is deleted
+type BucketArchive struct {
+	// Either Running, Completed or Failed
+	Phase string `json:"phase,omitempty"`
+	// Location of the archive, as bucket/prefix
+	Location string `json:"location,omitempty"`
+	// Objects copied so far
+	Copied int64 `json:"copied"`
+	// Bytes copied so far
+	CopiedBytes int64 `json:"copiedBytes"`
+	// Objects that could not be copied
+	Errors int64 `json:"errors,omitempty"`
+	// Last error encountered, if any
+	LastError string `json:"lastError,omitempty"`
+	// Time the archive started
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+	// Time the archive completed
+	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 }
 
 // BucketStatus defines the observed state of Bucket
@@ -22,10 +118,25 @@ type BucketStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	State   string `json:"state,omitempty" patchStrategy:"merge"`
 	Message string `json:"message,omitempty" patchStrategy:"merge"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Usage *BucketUsage `json:"usage,omitempty" patchStrategy:"merge"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Archive *BucketArchive `json:"archive,omitempty" patchStrategy:"merge"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Purge *BucketPurge `json:"purge,omitempty" patchStrategy:"merge"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
 //+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="Bucket",type=string,JSONPath=`.spec.name`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Size",type=string,JSONPath=`.status.usage.size`
+//+kubebuilder:printcolumn:name="Objects",type=integer,JSONPath=`.status.usage.objects`
+//+kubebuilder:printcolumn:name="Versions",type=integer,JSONPath=`.status.usage.versions`
+//+kubebuilder:printcolumn:name="Quota %",type=integer,JSONPath=`.status.usage.quotaUtilization`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
 
 // Bucket is the Schema for the buckets API
 type Bucket struct {
@@ -36,6 +147,11 @@ type Bucket struct {
 	Status BucketStatus `json:"status,omitempty"`
 }
 
+// IsDeletionBlocked reports whether the bucket is protected from deletion and the deletion was not confirmed
+func (b *Bucket) IsDeletionBlocked() bool {
+	return b.Spec.DeletionProtection && b.Annotations[ConfirmDeletionAnnotation] != b.Spec.Name
+}
+
 //+kubebuilder:object:root=true
 
 // BucketList contains a list of Bucket
@@ -0,0 +1,41 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+)
+
+// The webhook is registered by the operator's TenantPolicyValidator, which also checks the
+// MinioTenantPolicies of the bucket's namespace and delegates to the validations below
+
+//+kubebuilder:webhook:path=/validate-minio-scc-digitalhub-github-io-v1-bucket,mutating=false,failurePolicy=fail,sideEffects=None,groups=minio.scc-digitalhub.github.io,resources=buckets,verbs=create;update;delete,versions=v1,name=vbucket.kb.io,admissionReviewVersions=v1
+
+var _ webhook.Validator = &Bucket{}
+
+// ValidateCreate implements webhook.Validator
+func (b *Bucket) ValidateCreate() error {
+	return nil
+}
+
+// ValidateUpdate implements webhook.Validator
+func (b *Bucket) ValidateUpdate(old runtime.Object) error {
+	return nil
+}
+
+// ValidateDelete implements webhook.Validator, blocking deletes of protected buckets that were not confirmed.
+// It only runs when the operator serves webhooks (ENABLE_WEBHOOKS=true): the finalizer of the
+// Bucket controller is what keeps protected buckets in MinIO.
+func (b *Bucket) ValidateDelete() error {
+	if b.IsDeletionBlocked() {
+		return fmt.Errorf("bucket %s has deletion protection enabled, set the %s annotation to %s to delete it",
+			b.Spec.Name, ConfirmDeletionAnnotation, b.Spec.Name)
+	}
+
+	return nil
+}
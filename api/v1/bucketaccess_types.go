@@ -0,0 +1,59 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// BucketAccessSpec defines the desired state of BucketAccess
+type BucketAccessSpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern:=`^[a-z0-9]([a-z0-9\.-]){1,61}[a-z0-9]$`
+	BucketName string `json:"bucketName"`
+	// +kubebuilder:validation:Optional
+	Quota *resource.Quantity `json:"quota,omitempty"`
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=ro;rw;admin
+	// +kubebuilder:default:=rw
+	AccessMode string `json:"accessMode,omitempty"`
+	// Name of the Secret holding the generated credentials, defaults to <name>-credentials
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
+}
+
+// BucketAccessStatus defines the observed state of BucketAccess
+type BucketAccessStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	State      string `json:"state,omitempty" patchStrategy:"merge"`
+	Message    string `json:"message,omitempty" patchStrategy:"merge"`
+	SecretName string `json:"secretName,omitempty" patchStrategy:"merge"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+
+// BucketAccess is the Schema for the bucketaccesses API
+type BucketAccess struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   BucketAccessSpec   `json:"spec,omitempty"`
+	Status BucketAccessStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// BucketAccessList contains a list of BucketAccess
+type BucketAccessList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []BucketAccess `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&BucketAccess{}, &BucketAccessList{})
+}
@@ -0,0 +1,50 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ClusterPolicySpec defines the desired state of ClusterPolicy
+type ClusterPolicySpec struct {
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:Pattern:=`[^\s]*`
+	Name string `json:"name"`
+	// +kubebuilder:validation:Required
+	Content string `json:"content"`
+	// Whether deleting the policy is blocked while users reference it, or detaches it from them
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Block;Detach
+	// +kubebuilder:default:=Block
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:resource:scope=Cluster
+
+// ClusterPolicy is the Schema for the clusterpolicies API, a policy owned by the platform
+// rather than by a namespace
+type ClusterPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   ClusterPolicySpec `json:"spec,omitempty"`
+	Status PolicyStatus      `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// ClusterPolicyList contains a list of ClusterPolicy
+type ClusterPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []ClusterPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&ClusterPolicy{}, &ClusterPolicyList{})
+}
@@ -0,0 +1,52 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// MinioTenantPolicySpec defines what the resources of some namespaces may do in MinIO.
+// Patterns may contain * matching any sequence of characters and ? matching any single one.
+type MinioTenantPolicySpec struct {
+	// Patterns of the namespaces the restrictions apply to
+	// +kubebuilder:validation:Required
+	// +kubebuilder:validation:MinItems=1
+	Namespaces []string `json:"namespaces"`
+	// Patterns of the bucket names the namespaces may create and grant access to, any when empty
+	// +kubebuilder:validation:Optional
+	BucketNames []string `json:"bucketNames,omitempty"`
+	// Patterns of the actions the namespaces' policies may allow, any when empty
+	// +kubebuilder:validation:Optional
+	Actions []string `json:"actions,omitempty"`
+	// Largest quota of a bucket, buckets without a quota are not allowed when set
+	// +kubebuilder:validation:Optional
+	MaxQuota *resource.Quantity `json:"maxQuota,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:resource:scope=Cluster
+
+// MinioTenantPolicy is the Schema for the miniotenantpolicies API
+type MinioTenantPolicy struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec MinioTenantPolicySpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// MinioTenantPolicyList contains a list of MinioTenantPolicy
+type MinioTenantPolicyList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []MinioTenantPolicy `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&MinioTenantPolicy{}, &MinioTenantPolicyList{})
+}
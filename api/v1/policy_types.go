@@ -15,13 +15,31 @@ type PolicySpec struct {
 	Name string `json:"name"`
 	// +kubebuilder:validation:Required
 	Content string `json:"content"`
+	// PolicyFragment resources in the same namespace whose statements are merged into the content
+	// +kubebuilder:validation:Optional
+	Includes []string `json:"includes,omitempty"`
+	// Whether deleting the policy is blocked while users reference it, or detaches it from them
+	// +kubebuilder:validation:Optional
+	// +kubebuilder:validation:Enum=Block;Detach
+	// +kubebuilder:default:=Block
+	DeletionPolicy string `json:"deletionPolicy,omitempty"`
 }
 
+// Deletion policies of Policy resources
+const (
+	PolicyDeletionBlock  = "Block"
+	PolicyDeletionDetach = "Detach"
+)
+
 // PolicyStatus defines the observed state of Policy
 type PolicyStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	State   string `json:"state,omitempty" patchStrategy:"merge"`
 	Message string `json:"message,omitempty" patchStrategy:"merge"`
+	// User resources referencing the policy, as namespace/name
+	Users []string `json:"users,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
@@ -0,0 +1,108 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// Decisions of access checks
+const (
+	DecisionAllow        = "Allow"
+	DecisionDeny         = "Deny"
+	DecisionImplicitDeny = "ImplicitDeny"
+)
+
+// PolicyCheckSpec defines the access to evaluate, without changing anything in MinIO
+type PolicyCheckSpec struct {
+	// User resource in the same namespace whose attached policies are evaluated
+	// +kubebuilder:validation:Optional
+	User string `json:"user,omitempty"`
+	// Names in MinIO of Policy resources in the same namespace or of ClusterPolicies, whose specs
+	// are evaluated together with the user's policies
+	// +kubebuilder:validation:Optional
+	Policies []string `json:"policies,omitempty"`
+	// Requests to evaluate
+	// +kubebuilder:validation:Optional
+	Requests []AccessRequest `json:"requests,omitempty"`
+	// Values of the condition keys requests are evaluated with, e.g. aws:SourceIp,
+	// conditions on keys without a value do not match
+	// +kubebuilder:validation:Optional
+	Conditions map[string][]string `json:"conditions,omitempty"`
+	// Policy resource in the same namespace whose spec is compared with the policy in MinIO,
+	// reporting how the access of the users it is attached to changes
+	// +kubebuilder:validation:Optional
+	Policy string `json:"policy,omitempty"`
+}
+
+// AccessRequest is an action on a resource
+type AccessRequest struct {
+	// e.g. s3:GetObject
+	// +kubebuilder:validation:Required
+	Action string `json:"action"`
+	// e.g. arn:aws:s3:::my-bucket/my-object
+	// +kubebuilder:validation:Required
+	Resource string `json:"resource"`
+}
+
+// AccessResult is the decision on a request
+type AccessResult struct {
+	AccessRequest `json:",inline"`
+	// +kubebuilder:validation:Enum=Allow;Deny;ImplicitDeny
+	Decision string `json:"decision"`
+}
+
+// AccessChange is a request whose decision changes for a user once a policy is applied
+type AccessChange struct {
+	AccessRequest `json:",inline"`
+	// User resource losing or gaining access
+	User   string `json:"user"`
+	Before string `json:"before"`
+	After  string `json:"after"`
+}
+
+// PolicyCheckStatus defines the observed state of PolicyCheck
+type PolicyCheckStatus struct {
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	State   string `json:"state,omitempty" patchStrategy:"merge"`
+	Message string `json:"message,omitempty" patchStrategy:"merge"`
+	// Generation of the spec the results were evaluated for
+	ObservedGeneration int64 `json:"observedGeneration,omitempty"`
+	// Decisions on the requests
+	Results []AccessResult `json:"results,omitempty"`
+	// Changes of access caused by the policy
+	Changes []AccessChange `json:"changes,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
+}
+
+//+kubebuilder:object:root=true
+//+kubebuilder:subresource:status
+//+kubebuilder:printcolumn:name="User",type=string,JSONPath=`.spec.user`
+//+kubebuilder:printcolumn:name="Policy",type=string,JSONPath=`.spec.policy`
+//+kubebuilder:printcolumn:name="State",type=string,JSONPath=`.status.state`
+//+kubebuilder:printcolumn:name="Age",type=date,JSONPath=`.metadata.creationTimestamp`
+
+// PolicyCheck is the Schema for the policychecks API
+type PolicyCheck struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   PolicyCheckSpec   `json:"spec,omitempty"`
+	Status PolicyCheckStatus `json:"status,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PolicyCheckList contains a list of PolicyCheck
+type PolicyCheckList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyCheck `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyCheck{}, &PolicyCheckList{})
+}
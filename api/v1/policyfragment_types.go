@@ -0,0 +1,39 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package v1
+
+import (
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// PolicyFragmentSpec defines statements shared by policies
+type PolicyFragmentSpec struct {
+	// Multi-line JSON string of a policy whose statements are merged into the policies including it
+	// +kubebuilder:validation:Required
+	Content string `json:"content"`
+}
+
+//+kubebuilder:object:root=true
+
+// PolicyFragment is the Schema for the policyfragments API
+type PolicyFragment struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec PolicyFragmentSpec `json:"spec,omitempty"`
+}
+
+//+kubebuilder:object:root=true
+
+// PolicyFragmentList contains a list of PolicyFragment
+type PolicyFragmentList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []PolicyFragment `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&PolicyFragment{}, &PolicyFragmentList{})
+}
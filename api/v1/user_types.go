@@ -5,6 +5,7 @@
 package v1
 
 import (
+	corev1 "k8s.io/api/core/v1"
 	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 )
 
@@ -12,14 +13,41 @@ import (
 type UserSpec struct {
 	// +kubebuilder:validation:Required
 	AccessKey string `json:"accessKey"`
-	// +kubebuilder:validation:Required
-	SecretKey string `json:"secretKey"`
+	// Either secretKey, secretKeyRef or rotation is required
+	// +kubebuilder:validation:Optional
+	SecretKey string `json:"secretKey,omitempty"`
+	// Key of a Secret in the same namespace holding the secret key, used instead of secretKey
+	// +kubebuilder:validation:Optional
+	SecretKeyRef *corev1.SecretKeySelector `json:"secretKeyRef,omitempty"`
 	// +kubebuilder:validation:Optional
 	Policies []string `json:"policies,omitempty"`
 	// +kubebuilder:validation:Optional
 	// +kubebuilder:validation:Enum=enabled;disabled
 	// +kubebuilder:default:=enabled
 	AccountStatus string `json:"accountStatus,omitempty"`
+	// Rotate a generated secret key on a schedule, used instead of secretKey and secretKeyRef
+	// +kubebuilder:validation:Optional
+	Rotation *UserRotation `json:"rotation,omitempty"`
+	// Time after which the user is disabled
+	// +kubebuilder:validation:Optional
+	ExpiresAt *metav1.Time `json:"expiresAt,omitempty"`
+	// Delete the user this long after it expires, it is only disabled when unset
+	// +kubebuilder:validation:Optional
+	DeleteAfterExpiry *metav1.Duration `json:"deleteAfterExpiry,omitempty"`
+}
+
+// UserRotation defines how the secret key of a user is rotated
+type UserRotation struct {
+	// Time between rotations, e.g. 2160h for 90 days
+	// +kubebuilder:validation:Required
+	Interval metav1.Duration `json:"interval"`
+	// How long the previous secret key is kept in the Secret after a rotation, and MinIO keeps
+	// accepting it while the Deployments using the Secret roll out
+	// +kubebuilder:validation:Optional
+	GracePeriod *metav1.Duration `json:"gracePeriod,omitempty"`
+	// Secret holding the generated credentials, defaults to <name>-credentials
+	// +kubebuilder:validation:Optional
+	SecretName string `json:"secretName,omitempty"`
 }
 
 // UserStatus defines the observed state of User
@@ -27,6 +55,16 @@ type UserStatus struct {
 	// +operator-sdk:csv:customresourcedefinitions:type=status
 	State   string `json:"state,omitempty" patchStrategy:"merge"`
 	Message string `json:"message,omitempty" patchStrategy:"merge"`
+	// Hash of the credentials last set in MinIO, to only set them again when they change
+	CredentialsHash string `json:"credentialsHash,omitempty"`
+	// Time of the last rotation of the secret key
+	LastRotationTime *metav1.Time `json:"lastRotationTime,omitempty"`
+	// Time of the next rotation of the secret key
+	NextRotationTime *metav1.Time `json:"nextRotationTime,omitempty"`
+	// Time left until the user expires, or Expired
+	RemainingLifetime string `json:"remainingLifetime,omitempty"`
+	// +operator-sdk:csv:customresourcedefinitions:type=status
+	Conditions []metav1.Condition `json:"conditions,omitempty" patchStrategy:"merge" patchMergeKey:"type"`
 }
 
 //+kubebuilder:object:root=true
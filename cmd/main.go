@@ -9,6 +9,7 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
 	// Import all Kubernetes client auth plugins (e.g. Azure, GCP, OIDC, etc.)
 	// to ensure that exec-entrypoint and run can make use of them.
@@ -45,11 +46,17 @@ func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var minioCheckTimeout time.Duration
+	var minioCheckCacheDuration time.Duration
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.DurationVar(&minioCheckTimeout, "minio-check-timeout", 5*time.Second,
+		"Timeout of the MinIO connectivity check performed by the readiness probe.")
+	flag.DurationVar(&minioCheckCacheDuration, "minio-check-cache-duration", 30*time.Second,
+		"How long the outcome of the MinIO connectivity check is reused by the readiness probe.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -88,10 +95,32 @@ func main() {
 		os.Exit(1)
 	}
 
+	// MinIO is shared by all reconcilers and the readiness check
+	minioAPI := controller.NewMinioAPI()
+
+	// Buckets are archived and emptied before deletion in the background
+	bucketArchiver := controller.NewBucketArchiver(minioAPI)
+	if err = mgr.Add(bucketArchiver); err != nil {
+		setupLog.Error(err, "unable to add bucket archiver to manager")
+		os.Exit(1)
+	}
+	bucketPurger, err := controller.NewBucketPurger(minioAPI)
+	if err != nil {
+		setupLog.Error(err, "unable to set up bucket purger")
+		os.Exit(1)
+	}
+	if err = mgr.Add(bucketPurger); err != nil {
+		setupLog.Error(err, "unable to add bucket purger to manager")
+		os.Exit(1)
+	}
+
 	if err = (&controller.BucketReconciler{
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("bucket-controller"),
+		Minio:    minioAPI,
+		Purger:   bucketPurger,
+		Archiver: bucketArchiver,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMessage, "controller", "Bucket")
 		os.Exit(1)
@@ -100,6 +129,7 @@ func main() {
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("user-controller"),
+		Minio:    minioAPI,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMessage, "controller", "User")
 		os.Exit(1)
@@ -108,12 +138,51 @@ func main() {
 		Client:   mgr.GetClient(),
 		Scheme:   mgr.GetScheme(),
 		Recorder: mgr.GetEventRecorderFor("policy-controller"),
+		Minio:    minioAPI,
 	}).SetupWithManager(mgr); err != nil {
 		setupLog.Error(err, unableToCreateControllerMessage, "controller", "Policy")
 		os.Exit(1)
 	}
+	if err = (&controller.ClusterPolicyReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("clusterpolicy-controller"),
+		Minio:    minioAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, unableToCreateControllerMessage, "controller", "ClusterPolicy")
+		os.Exit(1)
+	}
+	if err = (&controller.BucketAccessReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("bucketaccess-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, unableToCreateControllerMessage, "controller", "BucketAccess")
+		os.Exit(1)
+	}
+	if err = (&controller.PolicyCheckReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("policycheck-controller"),
+		Minio:    minioAPI,
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, unableToCreateControllerMessage, "controller", "PolicyCheck")
+		os.Exit(1)
+	}
+	// Webhooks require serving certificates, see config/webhook
+	if os.Getenv("ENABLE_WEBHOOKS") == "true" {
+		if err = (&controller.TenantPolicyValidator{Client: mgr.GetClient()}).SetupWebhookWithManager(mgr); err != nil {
+			setupLog.Error(err, "unable to create webhook", "webhook", "TenantPolicy")
+			os.Exit(1)
+		}
+	}
 	//+kubebuilder:scaffold:builder
 
+	if err := controller.RegisterResourceStateMetrics(mgr.GetCache()); err != nil {
+		setupLog.Error(err, "unable to register metrics")
+		os.Exit(1)
+	}
+
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {
 		setupLog.Error(err, "unable to set up health check")
 		os.Exit(1)
@@ -122,6 +191,10 @@ func main() {
 		setupLog.Error(err, "unable to set up ready check")
 		os.Exit(1)
 	}
+	if err := mgr.AddReadyzCheck("minio", controller.MinioReadyCheck(minioAPI, minioCheckCacheDuration, minioCheckTimeout)); err != nil {
+		setupLog.Error(err, "unable to set up ready check")
+		os.Exit(1)
+	}
 
 	setupLog.Info("starting manager")
 	if err := mgr.Start(ctrl.SetupSignalHandler()); err != nil {
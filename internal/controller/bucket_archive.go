@@ -0,0 +1,345 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Archives are given up after this many consecutive objects failing to be copied
+const maxFailedArchiveCopies = 5
+
+// Metadata of archived objects holding the ETag of the source object, to skip it when resuming
+const archiveSourceETagMetadata = "Source-Etag"
+
+// Prefix of the IDs of the lifecycle rules expiring archives
+const archiveRetentionRulePrefix = "minio-operator-archive-"
+
+// Returned by the finalizer while the bucket is being archived
+var errBucketArchiving = errors.New("bucket is being archived")
+
+// ArchiveTarget is where a bucket is archived
+type ArchiveTarget struct {
+	Store  ObjectStore
+	Bucket string
+	// Prefix shared by all archives of the bucket, expired after RetentionDays when set
+	Prefix        string
+	RetentionDays int32
+	// Name of this archive under Prefix
+	Name string
+}
+
+// Location of the archive, as bucket/prefix
+func (t ArchiveTarget) location() string {
+	return t.Bucket + "/" + t.keyPrefix()
+}
+
+func (t ArchiveTarget) keyPrefix() string {
+	return t.Prefix + "/" + t.Name + "/"
+}
+
+// BucketArchiver copies buckets to an archive in the background before they are deleted,
+// so that large buckets do not block reconcile workers
+type BucketArchiver struct {
+	Minio MinioAPI
+
+	mu     sync.Mutex
+	ctx    context.Context
+	cancel context.CancelFunc
+	jobs   map[string]*archiveJob
+	// Builds the ObjectStore of archives on another MinIO instance
+	newStore func(endpoint string, useSSL bool, accessKey string, secretKey string) (ObjectStore, error)
+}
+
+type archiveJob struct {
+	mu       sync.Mutex
+	progress operatorv1.BucketArchive
+	err      error
+	cancel   context.CancelFunc
+}
+
+// NewBucketArchiver returns a BucketArchiver copying buckets from the given MinIO API
+func NewBucketArchiver(api MinioAPI) *BucketArchiver {
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BucketArchiver{
+		Minio:    api,
+		ctx:      ctx,
+		cancel:   cancel,
+		jobs:     map[string]*archiveJob{},
+		newStore: newRemoteObjectStore,
+	}
+}
+
+// Start implements manager.Runnable, stopping all archives when the manager stops
+func (a *BucketArchiver) Start(ctx context.Context) error {
+	<-ctx.Done()
+	a.cancel()
+	return nil
+}
+
+// Archive starts copying a bucket to the target unless it is already being copied, and returns
+// the progress so far. Failed archives are started again, skipping the objects already copied.
+// The error of a failed archive is returned as well.
+func (a *BucketArchiver) Archive(bucket string, target ArchiveTarget, previous *operatorv1.BucketArchive) (operatorv1.BucketArchive, error) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	job, found := a.jobs[bucket]
+	if found {
+		job.mu.Lock()
+		progress, err := *job.progress.DeepCopy(), job.err
+		job.mu.Unlock()
+
+		if progress.Phase != phaseFailed {
+			return progress, nil
+		}
+		// Report the failure once, the archive is started again on the next call
+		delete(a.jobs, bucket)
+		return progress, err
+	}
+
+	now := metav1.Now()
+	job = &archiveJob{progress: operatorv1.BucketArchive{
+		Phase:     phaseRunning,
+		Location:  target.location(),
+		StartTime: &now,
+	}}
+	if previous != nil && previous.StartTime != nil {
+		job.progress.StartTime = previous.StartTime
+	}
+
+	ctx, cancel := context.WithCancel(a.ctx)
+	job.cancel = cancel
+	a.jobs[bucket] = job
+
+	go a.run(ctx, bucket, target, job)
+
+	return *job.progress.DeepCopy(), nil
+}
+
+// Forget stops archiving a bucket and discards its progress
+func (a *BucketArchiver) Forget(bucket string) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if job, found := a.jobs[bucket]; found {
+		job.cancel()
+		delete(a.jobs, bucket)
+	}
+}
+
+func (a *BucketArchiver) run(ctx context.Context, bucket string, target ArchiveTarget, job *archiveJob) {
+	logger := log.Log.WithName("bucket-archiver").WithValues("bucket", bucket, "location", target.location())
+	logger.Info("Archiving bucket")
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	fail := func(err error) {
+		job.mu.Lock()
+		job.err = err
+		job.progress.Phase = phaseFailed
+		job.progress.LastError = err.Error()
+		job.mu.Unlock()
+		logger.Error(err, "Failed to archive bucket")
+	}
+
+	if err := prepareArchive(ctx, target); err != nil {
+		if ctx.Err() == nil {
+			fail(err)
+		}
+		return
+	}
+
+	// Objects already archived by a previous attempt are counted again as they are skipped
+	failedCopies := 0
+	var lastErr error
+	objects := a.Minio.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true})
+	defer func() {
+		cancel()
+		for range objects {
+			// The rest of the listing is cut short by the cancellation
+		}
+	}()
+	for object := range objects {
+		if ctx.Err() != nil {
+			return
+		}
+		if object.Err != nil {
+			if isNoSuchBucket(object.Err) {
+				// Nothing left to archive
+				break
+			}
+			fail(object.Err)
+			return
+		}
+
+		err := a.copyObject(ctx, bucket, object, target)
+		if ctx.Err() != nil {
+			return
+		}
+
+		job.mu.Lock()
+		if err != nil {
+			failedCopies++
+			lastErr = err
+			job.progress.Errors++
+			job.progress.LastError = err.Error()
+		} else {
+			failedCopies = 0
+			job.progress.Copied++
+			job.progress.CopiedBytes += object.Size
+		}
+		job.mu.Unlock()
+
+		if failedCopies >= maxFailedArchiveCopies {
+			fail(lastErr)
+			return
+		}
+	}
+
+	// Deletion only proceeds once every object is archived
+	if ctx.Err() != nil {
+		return
+	}
+	if lastErr != nil {
+		fail(lastErr)
+		return
+	}
+	if err := a.checkArchived(ctx, bucket, job); err != nil {
+		if ctx.Err() == nil {
+			fail(err)
+		}
+		return
+	}
+
+	now := metav1.Now()
+	job.mu.Lock()
+	job.progress.Phase = phaseCompleted
+	job.progress.CompletionTime = &now
+	job.mu.Unlock()
+	logger.Info("Bucket archived")
+}
+
+// Check that the whole bucket was copied, by listing it again and comparing the number of
+// objects with the ones copied
+func (a *BucketArchiver) checkArchived(ctx context.Context, bucket string, job *archiveJob) error {
+	var objects int64
+	for object := range a.Minio.ListObjects(ctx, bucket, minio.ListObjectsOptions{Recursive: true}) {
+		if isNoSuchBucket(object.Err) {
+			break
+		}
+		if object.Err != nil {
+			return object.Err
+		}
+		objects++
+	}
+
+	job.mu.Lock()
+	copied := job.progress.Copied
+	job.mu.Unlock()
+	if copied < objects {
+		return fmt.Errorf("only %d of %d objects were archived", copied, objects)
+	}
+
+	return nil
+}
+
+// Copy an object to the archive, unless it was already copied
+func (a *BucketArchiver) copyObject(ctx context.Context, bucket string, object minio.ObjectInfo, target ArchiveTarget) error {
+	key := target.keyPrefix() + object.Key
+
+	archived, err := target.Store.StatObject(ctx, target.Bucket, key)
+	if err == nil && archived.UserMetadata[archiveSourceETagMetadata] == object.ETag {
+		return nil
+	}
+	if err != nil && !hasErrorCode(err, codeNoSuchKey) {
+		return err
+	}
+
+	reader, info, err := a.Minio.GetObject(ctx, bucket, object.Key)
+	if hasErrorCode(err, codeNoSuchKey) {
+		// Deleted since it was listed
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	return target.Store.PutObject(ctx, target.Bucket, key, reader, info.Size, minio.PutObjectOptions{
+		ContentType:  info.ContentType,
+		UserMetadata: map[string]string{archiveSourceETagMetadata: info.ETag},
+	})
+}
+
+// Create the archive bucket if missing and expire archives after the retention, if any
+func prepareArchive(ctx context.Context, target ArchiveTarget) error {
+	err := target.Store.MakeBucket(ctx, target.Bucket)
+	if err != nil && !isBucketAlreadyOwned(err) {
+		return err
+	}
+
+	if target.RetentionDays == 0 {
+		return nil
+	}
+
+	config, err := target.Store.GetBucketLifecycle(ctx, target.Bucket)
+	if hasErrorCode(err, codeNoSuchLifecycleConfiguration) {
+		config, err = lifecycle.NewConfiguration(), nil
+	}
+	if err != nil {
+		return err
+	}
+
+	rule := lifecycle.Rule{
+		ID:         archiveRetentionRulePrefix + strings.ReplaceAll(target.Prefix, "/", "-"),
+		Status:     "Enabled",
+		RuleFilter: lifecycle.Filter{Prefix: target.Prefix + "/"},
+		Expiration: lifecycle.Expiration{Days: lifecycle.ExpirationDays(target.RetentionDays)},
+	}
+	for i, existing := range config.Rules {
+		if existing.ID == rule.ID {
+			if existing.Expiration.Days == rule.Expiration.Days && existing.RuleFilter.Prefix == rule.RuleFilter.Prefix {
+				return nil
+			}
+			config.Rules = append(config.Rules[:i], config.Rules[i+1:]...)
+			break
+		}
+	}
+	config.Rules = append(config.Rules, rule)
+
+	return target.Store.SetBucketLifecycle(ctx, target.Bucket, config)
+}
+
+func newRemoteObjectStore(endpoint string, useSSL bool, accessKey string, secretKey string) (ObjectStore, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	client, err := minio.New(endpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:    useSSL,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to create client for %s: %w", endpoint, err)
+	}
+
+	return newObjectStore(client), nil
+}
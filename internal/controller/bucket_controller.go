@@ -6,21 +6,31 @@ package controller
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"os"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/minio/madmin-go/v3"
-	"github.com/minio/minio-go/v7"
 
 	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
 )
@@ -29,16 +39,62 @@ const bucketFinalizer = "minio.scc-digitalhub.github.io/bucket-finalizer"
 
 const envEmptyBucketOnDelete = "MINIO_EMPTY_BUCKET_ON_DELETE"
 
+// Quota types
+const (
+	quotaTypeHard = "hard"
+	quotaTypeSoft = "soft"
+)
+
+const conditionQuotaThresholdExceeded = "QuotaThresholdExceeded"
+
+const envUsageRefreshInterval = "MINIO_USAGE_REFRESH_INTERVAL"
+const defaultUsageRefreshInterval = 5 * time.Minute
+
 // BucketReconciler reconciles a Bucket object
 type BucketReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	Minio    MinioAPI
+	Purger   *BucketPurger
+	Archiver *BucketArchiver
+
+	usage dataUsageCache
+}
+
+// dataUsageCache shares the usage of all buckets read from MinIO, which is computed for the
+// whole cluster, between the reconciles of a refresh interval
+type dataUsageCache struct {
+	mu        sync.Mutex
+	info      madmin.DataUsageInfo
+	fetchedAt time.Time
+}
+
+// Get the usage read from MinIO less than maxAge ago, reading it again if older
+func (c *dataUsageCache) get(ctx context.Context, minioAPI MinioAPI, maxAge time.Duration) (madmin.DataUsageInfo, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if !c.fetchedAt.IsZero() && time.Since(c.fetchedAt) < maxAge {
+		return c.info, nil
+	}
+
+	info, err := minioAPI.DataUsageInfo(ctx)
+	if err != nil {
+		return madmin.DataUsageInfo{}, err
+	}
+	c.info = info
+	c.fetchedAt = time.Now()
+
+	return info, nil
 }
 
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=buckets,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=buckets/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=buckets/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=miniotenantpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -58,6 +114,25 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
+	// Paused resources are only checked for drift, without writing to MinIO
+	paused, err := pausedReason(ctx, r.Client, cr)
+	if err != nil {
+		log.Error(err, "Failed to check if reconciliation is paused")
+		return ctrl.Result{}, err
+	}
+	if paused != "" {
+		return r.reportDrift(ctx, cr, paused)
+	}
+	if clearPausedConditions(&cr.Status.Conditions) {
+		log.Info("Resuming reconciliation")
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	// If status is unknown, set Creating
 	if cr.Status.State == "" {
 		log.Info("State unspecified, updating to creating")
@@ -70,25 +145,32 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// Resources violating the tenant policies of their namespace are not written to MinIO, nor
+	// are buckets owned by another namespace
+	if cr.GetDeletionTimestamp() == nil && cr.Status.State != typeError {
+		if err := checkTenantPolicies(ctx, r.Client, cr); err != nil {
+			log.Error(err, "Failed to check tenant policies")
+			return setBucketErrorState(r, ctx, cr, err)
+		}
+		if err := checkNameClaim(ctx, r.Client, cr); err != nil {
+			log.Error(err, "Failed to claim bucket name")
+			return setBucketErrorState(r, ctx, cr, err)
+		}
+	}
+
 	// Create resource, if it doesn't exist
 	if cr.Status.State == typeCreating {
 		log.Info("Creating resource")
 
-		client, err := getClient()
-		if err != nil {
-			log.Error(err, failedToObtainClientMessage)
-			return setBucketErrorState(r, ctx, cr, err)
-		}
-
 		// Create resource
-		err = client.MakeBucket(context.Background(), cr.Spec.Name, minio.MakeBucketOptions{})
-		if err != nil && !strings.Contains(err.Error(), "Your previous request to create the named bucket succeeded and you already own it") {
+		err = r.Minio.MakeBucket(ctx, cr.Spec.Name)
+		if err != nil && !isBucketAlreadyOwned(err) {
 			log.Error(err, "Error while creating bucket")
 			return setBucketErrorState(r, ctx, cr, err)
 		}
 
-		if cr.Spec.Quota != 0 {
-			err = setQuota(cr.Spec.Name, cr.Spec.Quota)
+		if quota := desiredQuota(cr); quota != 0 {
+			err = r.setQuota(ctx, cr.Spec.Name, quota)
 			if err != nil {
 				log.Error(err, "Failed to set quota")
 				return setBucketErrorState(r, ctx, cr, err)
@@ -115,6 +197,8 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		}
 
 		cr.Status.State = typeReady
+		cr.Status.Message = ""
+		setSyncedCondition(&cr.Status.Conditions, cr.Generation, nil)
 		if err = r.Status().Update(ctx, cr); err != nil {
 			log.Error(err, genericStatusUpdateFailedMessage)
 			return ctrl.Result{}, err
@@ -133,7 +217,19 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 			// Perform all operations required before removing the finalizer to allow
 			// the Kubernetes API to remove the custom resource.
-			if err := r.finalizerOpsForBucket(cr); err != nil {
+			err := trackFinalizer("Bucket", func() error { return r.finalizerOpsForBucket(ctx, cr) })
+			if isBucketDeletionPending(err) {
+				// The bucket is being archived or emptied in the background, report progress until it is done
+				log.Info("Waiting for bucket to be deleted", "reason", err.Error())
+				cr.Status.State = typeDeleting
+				if err := r.Status().Update(ctx, cr); err != nil {
+					log.Error(err, genericStatusUpdateFailedMessage)
+					return ctrl.Result{}, err
+				}
+
+				return ctrl.Result{RequeueAfter: purgeProgressInterval}, nil
+			}
+			if err != nil {
 				log.Error(err, "Finalizer operations failed")
 				return setBucketErrorState(r, ctx, cr, err)
 			}
@@ -164,26 +260,42 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		log.Info("Resource in Ready state")
 
 		// Check quota
-		adminClient, err := getAdminClient()
-		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
-			return setBucketErrorState(r, ctx, cr, err)
-		}
-
-		quota, err := adminClient.GetBucketQuota(context.Background(), cr.Spec.Name)
+		quota, err := r.Minio.GetBucketQuota(ctx, cr.Spec.Name)
 		if err != nil {
 			log.Error(err, "Failed to check resource properties")
+			return setBucketErrorState(r, ctx, cr, err)
 		}
 
-		if quota.Quota != cr.Spec.Quota {
+		if currentQuota(quota) != desiredQuota(cr) {
+			recordDriftCorrection("Bucket")
 			cr.Status.State = typeUpdating
 			if err = r.Status().Update(ctx, cr); err != nil {
 				log.Error(err, genericStatusUpdateFailedMessage)
 				return ctrl.Result{}, err
 			}
+
+			return ctrl.Result{}, nil
+		}
+
+		// Report usage and check it against the alert threshold
+		refreshInterval, err := readUsageRefreshInterval()
+		if err != nil {
+			log.Error(err, "Failed to read usage refresh interval")
+			return ctrl.Result{}, err
+		}
+		if refreshInterval == 0 {
+			if !hasQuotaAlert(cr) {
+				return ctrl.Result{}, nil
+			}
+			// Thresholds are checked even if usage is not reported otherwise
+			refreshInterval = defaultUsageRefreshInterval
 		}
 
-		return ctrl.Result{}, nil
+		if err = r.refreshUsage(ctx, cr, refreshInterval); err != nil {
+			log.Error(err, "Failed to refresh bucket usage")
+		}
+
+		return ctrl.Result{RequeueAfter: refreshInterval}, nil
 	}
 
 	// Update resource
@@ -191,7 +303,7 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		log.Info("Updating resource")
 
 		// Set quota
-		err = setQuota(cr.Spec.Name, cr.Spec.Quota)
+		err = r.setQuota(ctx, cr.Spec.Name, desiredQuota(cr))
 		if err != nil {
 			log.Error(err, "Failed to set quota")
 			return setBucketErrorState(r, ctx, cr, err)
@@ -199,6 +311,8 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 		// Update status
 		cr.Status.State = typeReady
+		cr.Status.Message = ""
+		setSyncedCondition(&cr.Status.Conditions, cr.Generation, nil)
 		if err = r.Status().Update(ctx, cr); err != nil {
 			log.Error(err, genericStatusUpdateFailedMessage)
 			return ctrl.Result{}, err
@@ -210,7 +324,19 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 	// Error state
 	if cr.Status.State == typeError {
 		log.Info("Resource in error state")
-		return ctrl.Result{}, nil
+
+		// Permanent errors are retried once the spec changes, any other on requeue
+		if !shouldRetryError(cr.Status.Conditions, cr.Generation) {
+			return ctrl.Result{}, nil
+		}
+
+		cr.Status.State = typeCreating
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
 	}
 
 	return ctrl.Result{}, nil
@@ -218,49 +344,63 @@ func (r *BucketReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *BucketReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &operatorv1.Bucket{}, minioNameIndex, indexBucketName); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&operatorv1.Bucket{}).
+		Watches(&source.Kind{Type: &operatorv1.MinioTenantPolicy{}}, handler.EnqueueRequestsFromMapFunc(
+			resourcesForTenantPolicy(r.Client, func() client.ObjectList { return &operatorv1.BucketList{} }))).
 		Complete(r)
 }
 
 // Perform required operations before deleting the CR
-func (r *BucketReconciler) finalizerOpsForBucket(cr *operatorv1.Bucket) error {
-	client, err := getClient()
+func (r *BucketReconciler) finalizerOpsForBucket(ctx context.Context, cr *operatorv1.Bucket) error {
+	if cr.IsDeletionBlocked() {
+		r.Recorder.Event(cr, "Warning", "DeletionProtected",
+			fmt.Sprintf("Bucket %s has deletion protection enabled, set the %s annotation to %s to delete it",
+				cr.Spec.Name, operatorv1.ConfirmDeletionAnnotation, cr.Spec.Name))
+		return fmt.Errorf("%w, set the %s annotation to %s to delete it",
+			errDeletionProtected, operatorv1.ConfirmDeletionAnnotation, cr.Spec.Name)
+	}
+
+	// The bucket belongs to another namespace, which must not lose its data
+	owner, err := nameOwnedElsewhere(ctx, r.Client, cr)
 	if err != nil {
 		return err
 	}
+	if owner != "" {
+		log.FromContext(ctx).Info("Leaving bucket owned by another namespace", "namespace", owner)
+		return nil
+	}
 
 	emptyBucketOnDelete, err := readEmptyBucketOnDelete()
 	if err != nil {
 		return err
 	}
 
-	// According to the documentation, client.RemoveObjects
-	// only deletes up to 1000 objects, hence the for loop
-	for {
-		err = client.RemoveBucket(context.Background(), cr.Spec.Name)
-		if err == nil || strings.Contains(err.Error(), "does not exist") {
-			err = nil
-			break
-		} else if strings.Contains(err.Error(), "not empty") && emptyBucketOnDelete {
-			// List objects
-			listOpts := minio.ListObjectsOptions{
-				Recursive:    true,
-				WithVersions: true,
-			}
-			objectsCh := client.ListObjects(context.Background(), cr.Spec.Name, listOpts)
+	if err := r.archiveBucket(ctx, cr); err != nil {
+		return err
+	}
 
-			// Delete them
-			client.RemoveObjects(context.Background(), cr.Spec.Name, objectsCh, minio.RemoveObjectsOptions{GovernanceBypass: true})
-		} else {
-			break
+	err = r.Minio.RemoveBucket(ctx, cr.Spec.Name)
+	if isBucketNotEmpty(err) && emptyBucketOnDelete {
+		// Buckets are emptied in the background, the finalizer is run again until they are
+		progress, err := r.Purger.Purge(cr.Spec.Name, cr.Status.Purge)
+		cr.Status.Purge = &progress
+		if err != nil {
+			return fmt.Errorf("failed to empty bucket: %w", err)
 		}
+		return errBucketPurging
 	}
-
-	if err != nil {
+	if err != nil && !isNoSuchBucket(err) {
 		return err
 	}
 
+	r.Purger.Forget(cr.Spec.Name)
+	deleteBucketUsageMetrics(cr)
+
 	// The following implementation will raise an event
 	r.Recorder.Event(cr, "Warning", "Deleting",
 		fmt.Sprintf("Custom Resource %s is being deleted from the namespace %s",
@@ -270,32 +410,148 @@ func (r *BucketReconciler) finalizerOpsForBucket(cr *operatorv1.Bucket) error {
 	return nil
 }
 
-func setBucketErrorState(r *BucketReconciler, ctx context.Context, cr *operatorv1.Bucket, err error) (ctrl.Result, error) {
+// Copy the bucket's contents to its archive, if any, before it is deleted
+func (r *BucketReconciler) archiveBucket(ctx context.Context, cr *operatorv1.Bucket) error {
+	if cr.Spec.OnDelete == nil || cr.Spec.OnDelete.ArchiveTo == nil {
+		return nil
+	}
+	if cr.Status.Archive != nil && cr.Status.Archive.Phase == phaseCompleted {
+		return nil
+	}
+
+	target, err := r.archiveTarget(ctx, cr)
+	if err != nil {
+		return fmt.Errorf("failed to archive bucket: %w", err)
+	}
+
+	progress, err := r.Archiver.Archive(cr.Spec.Name, target, cr.Status.Archive)
+	cr.Status.Archive = &progress
+	if err != nil {
+		return fmt.Errorf("failed to archive bucket: %w", err)
+	}
+	if progress.Phase != phaseCompleted {
+		return errBucketArchiving
+	}
+
+	r.Archiver.Forget(cr.Spec.Name)
+	r.Recorder.Event(cr, "Normal", "Archived",
+		fmt.Sprintf("Bucket %s archived to %s", cr.Spec.Name, progress.Location))
+
+	return nil
+}
+
+// Build the archive target of a bucket, on the operator's MinIO instance unless an endpoint is set
+func (r *BucketReconciler) archiveTarget(ctx context.Context, cr *operatorv1.Bucket) (ArchiveTarget, error) {
+	archiveTo := cr.Spec.OnDelete.ArchiveTo
+
+	target := ArchiveTarget{
+		Store:         r.Minio,
+		Bucket:        archiveTo.Bucket,
+		Prefix:        strings.Trim(archiveTo.Prefix, "/"),
+		RetentionDays: archiveTo.RetentionDays,
+		// Each deletion is archived separately
+		Name: cr.GetDeletionTimestamp().UTC().Format("20060102T150405Z"),
+	}
+	if target.Prefix == "" {
+		target.Prefix = cr.Spec.Name
+	}
+
+	if archiveTo.Endpoint == "" {
+		return target, nil
+	}
+	if archiveTo.CredentialsSecret == "" {
+		return ArchiveTarget{}, fmt.Errorf("credentialsSecret is required to archive to %s", archiveTo.Endpoint)
+	}
+
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: archiveTo.CredentialsSecret, Namespace: cr.Namespace}, secret)
+	if err != nil {
+		return ArchiveTarget{}, err
+	}
+	accessKey := string(secret.Data[secretAccessKeyIDKey])
+	secretKey := string(secret.Data[secretSecretAccessKeyKey])
+	if accessKey == "" || secretKey == "" {
+		return ArchiveTarget{}, fmt.Errorf("secret %s must contain %s and %s",
+			archiveTo.CredentialsSecret, secretAccessKeyIDKey, secretSecretAccessKeyKey)
+	}
+
+	target.Store, err = r.Archiver.newStore(archiveTo.Endpoint, archiveTo.UseSSL, accessKey, secretKey)
+	if err != nil {
+		return ArchiveTarget{}, err
+	}
+
+	return target, nil
+}
+
+// Whether the finalizer is waiting for the bucket to be archived or emptied in the background
+func isBucketDeletionPending(err error) bool {
+	return errors.Is(err, errBucketArchiving) || errors.Is(err, errBucketPurging)
+}
+
+// Report how the Bucket in MinIO differs from the spec, without changing it
+func (r *BucketReconciler) reportDrift(ctx context.Context, cr *operatorv1.Bucket, reason string) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
+	log.Info("Reconciliation paused, checking drift", "reason", reason)
 
-	cr.Status.State = typeError
-	cr.Status.Message = err.Error()
+	// Purges and archives are stopped, they resume from the progress in status
+	r.Purger.Forget(cr.Spec.Name)
+	r.Archiver.Forget(cr.Spec.Name)
+
+	drift, err := r.detectDrift(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to check drift")
+	}
 
+	setPausedConditions(&cr.Status.Conditions, cr.Generation, reason, drift, err)
 	if err := r.Status().Update(ctx, cr); err != nil {
 		log.Error(err, genericStatusUpdateFailedMessage)
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, err
+	return ctrl.Result{RequeueAfter: pausedDriftCheckInterval}, nil
 }
 
-func setQuota(bucketName string, value uint64) error {
-	adminClient, err := getAdminClient()
+// Return the differences between the bucket in MinIO and the spec
+func (r *BucketReconciler) detectDrift(ctx context.Context, cr *operatorv1.Bucket) ([]string, error) {
+	quota, err := r.Minio.GetBucketQuota(ctx, cr.Spec.Name)
+	if isNoSuchBucket(err) {
+		return []string{"bucket does not exist"}, nil
+	}
 	if err != nil {
-		return err
+		return nil, err
+	}
+
+	var drift []string
+	if currentQuota(quota) != desiredQuota(cr) {
+		drift = append(drift, fmt.Sprintf("quota is %d bytes, expected %d", currentQuota(quota), desiredQuota(cr)))
 	}
 
+	return drift, nil
+}
+
+func setBucketErrorState(r *BucketReconciler, ctx context.Context, cr *operatorv1.Bucket, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr.Status.State = typeError
+	cr.Status.Message = err.Error()
+	setSyncedCondition(&cr.Status.Conditions, cr.Generation, err)
+
+	if err := r.Status().Update(ctx, cr); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return resultForError(err)
+}
+
+func (r *BucketReconciler) setQuota(ctx context.Context, bucketName string, value uint64) error {
 	quota := &madmin.BucketQuota{
 		Quota: value,
+		Size:  value,
 		Type:  madmin.HardQuota,
 	}
 
-	err = adminClient.SetBucketQuota(context.Background(), bucketName, quota)
+	err := r.Minio.SetBucketQuota(ctx, bucketName, quota)
 	if err != nil {
 		return err
 	}
@@ -303,6 +559,134 @@ func setQuota(bucketName string, value uint64) error {
 	return nil
 }
 
+// Refresh usage reported in the status and metrics, emitting a warning event when the quota alert
+// threshold is crossed
+func (r *BucketReconciler) refreshUsage(ctx context.Context, cr *operatorv1.Bucket, refreshInterval time.Duration) error {
+	dataUsage, err := r.usage.get(ctx, r.Minio, refreshInterval)
+	if err != nil {
+		return err
+	}
+
+	bucketUsage := dataUsage.BucketsUsage[cr.Spec.Name]
+	usage := &operatorv1.BucketUsage{
+		Size:     resource.NewQuantity(int64(bucketUsage.Size), resource.BinarySI),
+		Objects:  bucketUsage.ObjectsCount,
+		Versions: bucketUsage.VersionsCount,
+	}
+	if !dataUsage.LastUpdate.IsZero() {
+		lastUpdate := metav1.NewTime(dataUsage.LastUpdate)
+		usage.LastUpdate = &lastUpdate
+	}
+
+	var limit uint64
+	if cr.Spec.Quota != nil && cr.Spec.Quota.Value() > 0 {
+		limit = uint64(cr.Spec.Quota.Value())
+		utilization := int32(bucketUsage.Size * 100 / limit)
+		usage.QuotaUtilization = &utilization
+	}
+
+	setBucketUsageMetrics(cr, bucketUsage, limit)
+
+	changed := !equality.Semantic.DeepEqual(cr.Status.Usage, usage)
+	cr.Status.Usage = usage
+
+	if r.updateQuotaThresholdCondition(cr, bucketUsage.Size, limit) {
+		changed = true
+	}
+
+	if !changed {
+		return nil
+	}
+
+	return r.Status().Update(ctx, cr)
+}
+
+// Set the quota threshold condition, raising an event when the threshold is crossed.
+// Returns whether the conditions changed.
+func (r *BucketReconciler) updateQuotaThresholdCondition(cr *operatorv1.Bucket, size uint64, limit uint64) bool {
+	threshold := quotaAlertThreshold(cr)
+	if limit == 0 || threshold == 0 {
+		if meta.FindStatusCondition(cr.Status.Conditions, conditionQuotaThresholdExceeded) == nil {
+			return false
+		}
+		meta.RemoveStatusCondition(&cr.Status.Conditions, conditionQuotaThresholdExceeded)
+		return true
+	}
+
+	percentage := size * 100 / limit
+	condition := metav1.Condition{
+		Type:               conditionQuotaThresholdExceeded,
+		Status:             metav1.ConditionFalse,
+		Reason:             "BelowThreshold",
+		Message:            fmt.Sprintf("Bucket usage is at %d%% of the quota", percentage),
+		ObservedGeneration: cr.Generation,
+	}
+	if size > limit {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "QuotaExceeded"
+	} else if percentage >= uint64(threshold) {
+		condition.Status = metav1.ConditionTrue
+		condition.Reason = "AboveThreshold"
+	}
+
+	previous := meta.FindStatusCondition(cr.Status.Conditions, conditionQuotaThresholdExceeded)
+	if previous != nil && previous.Status == condition.Status && previous.Reason == condition.Reason &&
+		previous.Message == condition.Message && previous.ObservedGeneration == condition.ObservedGeneration {
+		return false
+	}
+
+	// Only raise an event when the bucket crosses the threshold, not on every check
+	if condition.Status == metav1.ConditionTrue && (previous == nil || previous.Reason != condition.Reason) {
+		r.Recorder.Event(cr, "Warning", condition.Reason, condition.Message)
+	}
+
+	meta.SetStatusCondition(&cr.Status.Conditions, condition)
+	return true
+}
+
+// Percentage of the quota raising an alert, soft quotas alert when exceeded. 0 when not alerting.
+func quotaAlertThreshold(cr *operatorv1.Bucket) int32 {
+	if cr.Spec.QuotaAlertThreshold == 0 && cr.Spec.QuotaType == quotaTypeSoft {
+		return 100
+	}
+	return cr.Spec.QuotaAlertThreshold
+}
+
+func hasQuotaAlert(cr *operatorv1.Bucket) bool {
+	return cr.Spec.Quota != nil && cr.Spec.Quota.Value() > 0 && quotaAlertThreshold(cr) > 0
+}
+
+// Quota to be enforced by MinIO, soft quotas are never set on the bucket
+func desiredQuota(cr *operatorv1.Bucket) uint64 {
+	if cr.Spec.Quota == nil || cr.Spec.QuotaType == quotaTypeSoft || cr.Spec.Quota.Value() <= 0 {
+		return 0
+	}
+	return uint64(cr.Spec.Quota.Value())
+}
+
+// Quota currently set on the bucket, older MinIO releases only fill the deprecated field
+func currentQuota(quota madmin.BucketQuota) uint64 {
+	if quota.Size != 0 {
+		return quota.Size
+	}
+	return quota.Quota
+}
+
+func readUsageRefreshInterval() (time.Duration, error) {
+	refreshInterval := defaultUsageRefreshInterval
+
+	refreshIntervalString, found := os.LookupEnv(envUsageRefreshInterval)
+	if found {
+		refreshIntervalParsed, err := time.ParseDuration(refreshIntervalString)
+		if err != nil || refreshIntervalParsed < 0 {
+			return 0, fmt.Errorf("%s must be a valid duration, e.g. 5m", envUsageRefreshInterval)
+		}
+		refreshInterval = refreshIntervalParsed
+	}
+
+	return refreshInterval, nil
+}
+
 func readEmptyBucketOnDelete() (bool, error) {
 	emptyBucketOnDelete := false
 
@@ -0,0 +1,775 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"testing"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func newTestBucket(quota string) *operatorv1.Bucket {
+	bucket := &operatorv1.Bucket{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-bucket", Namespace: "default"},
+		Spec:       operatorv1.BucketSpec{Name: "test-bucket"},
+	}
+	if quota != "" {
+		q := resource.MustParse(quota)
+		bucket.Spec.Quota = &q
+	}
+	return bucket
+}
+
+func newTestBucketReconciler(t *testing.T, cr *operatorv1.Bucket, objs ...client.Object) (*BucketReconciler, *fakeMinio) {
+	t.Setenv(envPurgeRate, "0")
+
+	minio := newFakeMinio()
+	purger, err := NewBucketPurger(minio)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(purger.cancel)
+	archiver := NewBucketArchiver(minio)
+	t.Cleanup(archiver.cancel)
+
+	c := newFakeClient(t, append(objs, cr)...)
+	return &BucketReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+		Minio:    minio,
+		Purger:   purger,
+		Archiver: archiver,
+	}, minio
+}
+
+// Wait for the purge of a bucket to reach the given phase, returning its progress
+func waitForPurge(t *testing.T, purger *BucketPurger, bucket string, phase string) operatorv1.BucketPurge {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		purger.mu.Lock()
+		job, found := purger.jobs[bucket]
+		purger.mu.Unlock()
+
+		if found {
+			job.mu.Lock()
+			progress := *job.progress.DeepCopy()
+			job.mu.Unlock()
+			if progress.Phase == phase {
+				return progress
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("purge of %s did not reach phase %s", bucket, phase)
+	return operatorv1.BucketPurge{}
+}
+
+func TestBucketReconcileCreate(t *testing.T) {
+	cr := newTestBucket("10Mi")
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeCreating {
+		t.Fatalf("expected state %s, got %s", typeCreating, cr.Status.State)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+	if !controllerutil.ContainsFinalizer(cr, bucketFinalizer) {
+		t.Fatal("expected finalizer to be added")
+	}
+	if _, found := minio.buckets["test-bucket"]; !found {
+		t.Fatal("expected bucket to be created")
+	}
+	if minio.quotas["test-bucket"] != 10*1024*1024 {
+		t.Fatalf("expected quota of 10Mi, got %d", minio.quotas["test-bucket"])
+	}
+}
+
+func TestBucketReconcileAlreadyExists(t *testing.T) {
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+	minio.buckets["test-bucket"] = map[string]*fakeObject{}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+}
+
+func TestBucketReconcileCreateError(t *testing.T) {
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+	minio.fail("MakeBucket", s3Error(400, "InvalidBucketName", "The specified bucket is not valid."))
+
+	// Permanent errors are not retried
+	result := reconcileOnce(t, r, cr, false)
+	if !result.IsZero() {
+		t.Fatalf("expected no requeue, got %+v", result)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+	if cr.Status.Message != "The specified bucket is not valid." {
+		t.Fatalf("unexpected message %q", cr.Status.Message)
+	}
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if condition == nil || condition.Reason != string(errorClassPermanent) {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+
+	minio.fail("MakeBucket", nil)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+
+	// Until the spec changes
+	cr.Spec.Name = "other-bucket"
+	cr.Generation++
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+}
+
+func TestBucketReconcileRetryableError(t *testing.T) {
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+	minio.fail("MakeBucket", s3Error(503, "XMinioServerNotInitialized", "Server not initialized, please try again."))
+
+	// Retryable errors are returned to be requeued with backoff
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if cr.Status.State != typeError || condition == nil || condition.Reason != string(errorClassRetryable) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+
+	minio.fail("MakeBucket", nil)
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady || cr.Status.Message != "" {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	condition = meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if condition == nil || condition.Status != metav1.ConditionTrue {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+}
+
+func TestBucketReconcileQuotaDrift(t *testing.T) {
+	cr := newTestBucket("10Mi")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// Quota removed out of band
+	minio.quotas["test-bucket"] = 0
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeUpdating {
+		t.Fatalf("expected state %s, got %s", typeUpdating, cr.Status.State)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+	if minio.quotas["test-bucket"] != 10*1024*1024 {
+		t.Fatalf("expected quota to be restored, got %d", minio.quotas["test-bucket"])
+	}
+}
+
+func TestBucketReconcileQuotaCheckError(t *testing.T) {
+	cr := newTestBucket("10Mi")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// A failed check is not taken for a missing quota
+	minio.fail("GetBucketQuota", s3Error(503, "SlowDown", "Please reduce your request rate."))
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+}
+
+func TestBucketReconcilePaused(t *testing.T) {
+	cr := newTestBucket("10Mi")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	cr.Annotations = map[string]string{pausedAnnotation: "true"}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	minio.quotas["test-bucket"] = 0
+
+	// Drift is reported but not corrected
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != pausedDriftCheckInterval {
+		t.Fatalf("expected requeue after %s, got %+v", pausedDriftCheckInterval, result)
+	}
+	refetch(t, r.Client, cr)
+	if !meta.IsStatusConditionTrue(cr.Status.Conditions, conditionPaused) {
+		t.Fatalf("expected Paused condition, got %+v", cr.Status.Conditions)
+	}
+	drifted := meta.FindStatusCondition(cr.Status.Conditions, conditionDrifted)
+	if drifted == nil || drifted.Status != metav1.ConditionTrue || drifted.Message != "quota is 0 bytes, expected 10485760" {
+		t.Fatalf("unexpected Drifted condition %+v", drifted)
+	}
+	if minio.calls["SetBucketQuota"] != 1 {
+		t.Fatalf("expected quota not to be set while paused, got %d calls", minio.calls["SetBucketQuota"])
+	}
+
+	// Once resumed, the drift is corrected
+	delete(cr.Annotations, pausedAnnotation)
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 3; i++ {
+		reconcileOnce(t, r, cr, false)
+	}
+	refetch(t, r.Client, cr)
+	if meta.FindStatusCondition(cr.Status.Conditions, conditionPaused) != nil ||
+		meta.FindStatusCondition(cr.Status.Conditions, conditionDrifted) != nil {
+		t.Fatalf("expected paused conditions to be removed, got %+v", cr.Status.Conditions)
+	}
+	if minio.quotas["test-bucket"] != 10*1024*1024 {
+		t.Fatalf("expected quota to be restored, got %d", minio.quotas["test-bucket"])
+	}
+}
+
+func TestBucketReconcileUsage(t *testing.T) {
+	cr := newTestBucket("1Ki")
+	cr.Spec.QuotaAlertThreshold = 80
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	minio.putObject("test-bucket", "a", 900)
+
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != defaultUsageRefreshInterval {
+		t.Fatalf("expected requeue after %s, got %s", defaultUsageRefreshInterval, result.RequeueAfter)
+	}
+
+	refetch(t, r.Client, cr)
+	if cr.Status.Usage == nil || cr.Status.Usage.Objects != 1 || cr.Status.Usage.Size.Value() != 900 {
+		t.Fatalf("unexpected usage %+v", cr.Status.Usage)
+	}
+	if *cr.Status.Usage.QuotaUtilization != 87 {
+		t.Fatalf("expected utilization of 87%%, got %d", *cr.Status.Usage.QuotaUtilization)
+	}
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionQuotaThresholdExceeded)
+	if condition == nil || condition.Status != metav1.ConditionTrue || condition.Reason != "AboveThreshold" {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+	if len(r.Recorder.(*record.FakeRecorder).Events) != 1 {
+		t.Fatal("expected a warning event")
+	}
+
+	// The event is not raised again while the bucket stays above the threshold
+	reconcileOnce(t, r, cr, false)
+	if len(r.Recorder.(*record.FakeRecorder).Events) != 1 {
+		t.Fatal("expected no further events")
+	}
+}
+
+func TestBucketReconcileUsageShared(t *testing.T) {
+	t.Setenv(envUsageRefreshInterval, "0")
+
+	cr := newTestBucket("1Ki")
+	cr.Spec.QuotaType = quotaTypeSoft
+	cr.Status.State = typeReady
+	other := newTestBucket("")
+	other.Name = "other-bucket"
+	other.Spec.Name = "other-bucket"
+	other.Status.State = typeReady
+	r, minio := newTestBucketReconciler(t, cr, other)
+	minio.buckets["test-bucket"] = map[string]*fakeObject{}
+	minio.buckets["other-bucket"] = map[string]*fakeObject{}
+	minio.putObject("test-bucket", "a", 2048)
+
+	// Soft quotas are checked even with the refresh disabled
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != defaultUsageRefreshInterval {
+		t.Fatalf("expected requeue after %s, got %s", defaultUsageRefreshInterval, result.RequeueAfter)
+	}
+	refetch(t, r.Client, cr)
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionQuotaThresholdExceeded)
+	if condition == nil || condition.Reason != "QuotaExceeded" {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+
+	// Buckets without alerts are not refreshed
+	if result := reconcileOnce(t, r, other, false); !result.IsZero() {
+		t.Fatalf("expected no requeue, got %+v", result)
+	}
+
+	// Usage is read once per interval for all buckets
+	reconcileOnce(t, r, cr, false)
+	if minio.calls["DataUsageInfo"] != 1 {
+		t.Fatalf("expected usage to be read once, got %d", minio.calls["DataUsageInfo"])
+	}
+}
+
+func TestBucketReconcileDelete(t *testing.T) {
+	t.Setenv(envEmptyBucketOnDelete, "true")
+
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	minio.putObject("test-bucket", "a", 1)
+	minio.putObject("test-bucket", "b", 1)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	// The bucket is emptied in the background
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != purgeProgressInterval {
+		t.Fatalf("expected requeue after %s, got %+v", purgeProgressInterval, result)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeDeleting || cr.Status.Purge == nil {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if !controllerutil.ContainsFinalizer(cr, bucketFinalizer) {
+		t.Fatal("expected finalizer to be kept while emptying the bucket")
+	}
+
+	progress := waitForPurge(t, r.Purger, "test-bucket", phaseCompleted)
+	if progress.Deleted != 2 || *progress.Remaining != 0 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.buckets["test-bucket"]; found {
+		t.Fatal("expected bucket to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Bucket{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestBucketReconcileDeleteNotEmpty(t *testing.T) {
+	t.Setenv(envEmptyBucketOnDelete, "false")
+
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	minio.putObject("test-bucket", "a", 1)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Conflicts are checked again after a delay
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != conflictRequeueDelay {
+		t.Fatalf("expected requeue after %s, got %+v", conflictRequeueDelay, result)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+	if !controllerutil.ContainsFinalizer(cr, bucketFinalizer) {
+		t.Fatal("expected finalizer to be kept")
+	}
+}
+
+func TestBucketReconcileDeleteMissingBucket(t *testing.T) {
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	delete(minio.buckets, "test-bucket")
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Bucket{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestBucketReconcileDeleteProtected(t *testing.T) {
+	cr := newTestBucket("")
+	cr.Spec.DeletionProtection = true
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	// The finalizer refuses to proceed without waiting for a retry
+	result := reconcileOnce(t, r, cr, false)
+	if !result.IsZero() {
+		t.Fatalf("expected no requeue, got %+v", result)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || !controllerutil.ContainsFinalizer(cr, bucketFinalizer) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if _, found := minio.buckets["test-bucket"]; !found {
+		t.Fatal("expected bucket to be kept")
+	}
+	if err := cr.ValidateDelete(); err == nil {
+		t.Fatal("expected webhook to reject the deletion")
+	}
+
+	// Confirming with another name is not enough
+	refetch(t, r.Client, cr)
+	cr.Annotations = map[string]string{operatorv1.ConfirmDeletionAnnotation: "other-bucket"}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.buckets["test-bucket"]; !found {
+		t.Fatal("expected bucket to be kept")
+	}
+
+	refetch(t, r.Client, cr)
+	cr.Annotations[operatorv1.ConfirmDeletionAnnotation] = "test-bucket"
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	if err := cr.ValidateDelete(); err != nil {
+		t.Fatalf("expected webhook to allow the deletion, got %v", err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.buckets["test-bucket"]; found {
+		t.Fatal("expected bucket to be removed")
+	}
+}
+
+func TestBucketReconcileDeleteInBatches(t *testing.T) {
+	t.Setenv(envEmptyBucketOnDelete, "true")
+
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	for i := 0; i < 2*emptyBatchSize+1; i++ {
+		minio.putObject("test-bucket", fmt.Sprintf("object-%d", i), 1)
+	}
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	progress := waitForPurge(t, r.Purger, "test-bucket", phaseCompleted)
+	if progress.Deleted != 2*emptyBatchSize+1 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+	if minio.calls["RemoveObjects"] < 3 {
+		t.Fatalf("expected objects to be removed in batches, got %d calls", minio.calls["RemoveObjects"])
+	}
+}
+
+func TestBucketReconcileDeletePurgeFailure(t *testing.T) {
+	t.Setenv(envEmptyBucketOnDelete, "true")
+
+	cr := newTestBucket("")
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	minio.putObject("test-bucket", "a", 1)
+	minio.fail("RemoveObjects", s3Error(503, "SlowDown", "Please reduce your request rate."))
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	// The purge gives up after consecutive failed batches, reporting the error
+	reconcileOnce(t, r, cr, false)
+	waitForPurge(t, r.Purger, "test-bucket", phaseFailed)
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || cr.Status.Purge == nil || cr.Status.Purge.Errors != maxFailedPurgeBatches {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if cr.Status.Purge.LastError != "Please reduce your request rate." {
+		t.Fatalf("unexpected last error %q", cr.Status.Purge.LastError)
+	}
+
+	// And is started again on the next reconcile
+	minio.fail("RemoveObjects", nil)
+	reconcileOnce(t, r, cr, false)
+	waitForPurge(t, r.Purger, "test-bucket", phaseCompleted)
+	reconcileOnce(t, r, cr, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Bucket{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+// Wait for the archive of a bucket to reach the given phase, returning its progress
+func waitForArchive(t *testing.T, archiver *BucketArchiver, bucket string, phase string) operatorv1.BucketArchive {
+	t.Helper()
+
+	deadline := time.Now().Add(5 * time.Second)
+	for time.Now().Before(deadline) {
+		archiver.mu.Lock()
+		job, found := archiver.jobs[bucket]
+		archiver.mu.Unlock()
+
+		if found {
+			job.mu.Lock()
+			progress := *job.progress.DeepCopy()
+			job.mu.Unlock()
+			if progress.Phase == phase {
+				return progress
+			}
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Fatalf("archive of %s did not reach phase %s", bucket, phase)
+	return operatorv1.BucketArchive{}
+}
+
+// Create a bucket archived to archiveTo on deletion, holding two objects, and mark it for deletion
+func newDeletedArchivedBucket(t *testing.T, archiveTo operatorv1.BucketArchiveTarget) (*operatorv1.Bucket, *BucketReconciler, *fakeMinio) {
+	t.Helper()
+	t.Setenv(envEmptyBucketOnDelete, "true")
+
+	cr := newTestBucket("")
+	cr.Spec.OnDelete = &operatorv1.BucketOnDelete{ArchiveTo: &archiveTo}
+	cr.Status.State = typeCreating
+	r, minio := newTestBucketReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	minio.putObject("test-bucket", "a", 3)
+	minio.putObject("test-bucket", "dir/b", 5)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	refetch(t, r.Client, cr)
+
+	return cr, r, minio
+}
+
+func TestBucketReconcileDeleteArchive(t *testing.T) {
+	cr, r, minio := newDeletedArchivedBucket(t, operatorv1.BucketArchiveTarget{Bucket: "archive", RetentionDays: 30})
+	name := cr.GetDeletionTimestamp().UTC().Format("20060102T150405Z")
+
+	// The bucket is archived before being emptied
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != purgeProgressInterval {
+		t.Fatalf("expected requeue after %s, got %+v", purgeProgressInterval, result)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeDeleting || cr.Status.Archive == nil || cr.Status.Purge != nil {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if cr.Status.Archive.Location != "archive/test-bucket/"+name+"/" {
+		t.Fatalf("unexpected location %s", cr.Status.Archive.Location)
+	}
+
+	progress := waitForArchive(t, r.Archiver, "test-bucket", phaseCompleted)
+	if progress.Copied != 2 || progress.CopiedBytes != 8 || progress.CompletionTime == nil {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+	for _, key := range []string{"a", "dir/b"} {
+		archived, found := minio.buckets["archive"]["test-bucket/"+name+"/"+key]
+		if !found {
+			t.Fatalf("expected %s to be archived", key)
+		}
+		source := minio.buckets["test-bucket"][key]
+		if archived.metadata[archiveSourceETagMetadata] != source.info(key).ETag {
+			t.Fatalf("unexpected metadata %v", archived.metadata)
+		}
+	}
+	rules := minio.lifecycles["archive"].Rules
+	if len(rules) != 1 || rules[0].RuleFilter.Prefix != "test-bucket/" || rules[0].Expiration.Days != 30 {
+		t.Fatalf("unexpected lifecycle rules %+v", rules)
+	}
+
+	// Then emptied and removed
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.Archive.Phase != phaseCompleted || cr.Status.Purge == nil {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	waitForPurge(t, r.Purger, "test-bucket", phaseCompleted)
+	reconcileOnce(t, r, cr, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Bucket{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+	if len(minio.buckets["archive"]) != 2 {
+		t.Fatalf("expected archive to be kept, got %v", minio.buckets["archive"])
+	}
+}
+
+func TestBucketReconcileDeleteArchiveRemote(t *testing.T) {
+	cr, r, minio := newDeletedArchivedBucket(t, operatorv1.BucketArchiveTarget{
+		Bucket:            "archive",
+		Prefix:            "/backups/",
+		Endpoint:          "archive.example.com:9000",
+		CredentialsSecret: "archive-credentials",
+	})
+
+	remote := newFakeMinio()
+	r.Archiver.newStore = func(endpoint string, useSSL bool, accessKey string, secretKey string) (ObjectStore, error) {
+		if endpoint != "archive.example.com:9000" || accessKey != "archive" || secretKey != "archive-secret" {
+			t.Fatalf("unexpected remote store %s %s %s", endpoint, accessKey, secretKey)
+		}
+		return remote, nil
+	}
+
+	// Credentials are required
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected error state, got %s", cr.Status.State)
+	}
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "archive-credentials", Namespace: "default"},
+		Data: map[string][]byte{
+			secretAccessKeyIDKey:     []byte("archive"),
+			secretSecretAccessKeyKey: []byte("archive-secret"),
+		},
+	}
+	if err := r.Create(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	waitForArchive(t, r.Archiver, "test-bucket", phaseCompleted)
+
+	name := cr.GetDeletionTimestamp().UTC().Format("20060102T150405Z")
+	if _, found := remote.buckets["archive"]["backups/"+name+"/dir/b"]; !found {
+		t.Fatalf("expected object to be archived remotely, got %v", remote.buckets["archive"])
+	}
+	if _, found := minio.buckets["archive"]; found {
+		t.Fatal("expected no local archive bucket")
+	}
+}
+
+func TestBucketReconcileDeleteArchiveFailure(t *testing.T) {
+	cr, r, minio := newDeletedArchivedBucket(t, operatorv1.BucketArchiveTarget{Bucket: "archive"})
+	minio.fail("PutObject", s3Error(503, "SlowDown", "Please reduce your request rate."))
+
+	// Deletion is blocked until every object is archived
+	reconcileOnce(t, r, cr, false)
+	waitForArchive(t, r.Archiver, "test-bucket", phaseFailed)
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || cr.Status.Archive == nil || cr.Status.Archive.Errors != 2 {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if len(minio.buckets["test-bucket"]) != 2 {
+		t.Fatal("expected bucket contents to be kept")
+	}
+
+	// And is resumed once MinIO recovers
+	minio.fail("PutObject", nil)
+	reconcileOnce(t, r, cr, false)
+	progress := waitForArchive(t, r.Archiver, "test-bucket", phaseCompleted)
+	if progress.Copied != 2 || progress.Errors != 0 {
+		t.Fatalf("unexpected progress %+v", progress)
+	}
+}
+
+func TestBucketArchiverSlowCopies(t *testing.T) {
+	previous := operationTimeout
+	operationTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { operationTimeout = previous })
+
+	// Copying takes longer than the operation timeout
+	archiver := NewBucketArchiver(newStubMinioAPI(t, stubS3{objects: 10, objectDelay: 50 * time.Millisecond}))
+	t.Cleanup(archiver.cancel)
+	target := ArchiveTarget{Store: newFakeMinio(), Bucket: "archive", Prefix: "test-bucket", Name: "archive"}
+
+	if _, err := archiver.Archive("test-bucket", target, nil); err != nil {
+		t.Fatal(err)
+	}
+	progress := waitForArchive(t, archiver, "test-bucket", phaseCompleted)
+	if progress.Copied != 10 {
+		t.Fatalf("expected 10 objects to be archived, got %+v", progress)
+	}
+}
+
+func TestBucketArchiverListingFailure(t *testing.T) {
+	archiver := NewBucketArchiver(newStubMinioAPI(t, stubS3{objects: 10, pageSize: 4, failNextPages: true}))
+	t.Cleanup(archiver.cancel)
+	target := ArchiveTarget{Store: newFakeMinio(), Bucket: "archive", Prefix: "test-bucket", Name: "archive"}
+
+	if _, err := archiver.Archive("test-bucket", target, nil); err != nil {
+		t.Fatal(err)
+	}
+	progress := waitForArchive(t, archiver, "test-bucket", phaseFailed)
+	if progress.Copied != 4 {
+		t.Fatalf("expected the first page to be archived, got %+v", progress)
+	}
+}
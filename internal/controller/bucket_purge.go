@@ -0,0 +1,295 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"golang.org/x/time/rate"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const envPurgeRate = "MINIO_PURGE_RATE"
+
+// Objects deleted per second across all purges
+const defaultPurgeRate = 1000
+
+// Objects removed per batch when emptying a bucket, the most allowed by a single delete request
+const emptyBatchSize = 1000
+
+// Purges are given up after this many consecutive batches without deleting anything
+const maxFailedPurgeBatches = 5
+
+// How often the progress of a purge is reported in the Bucket's status
+const purgeProgressInterval = 10 * time.Second
+
+// Phases of purges and archives
+const (
+	phaseRunning   = "Running"
+	phaseCompleted = "Completed"
+	phaseFailed    = "Failed"
+)
+
+// Returned by the finalizer while the bucket is being emptied
+var errBucketPurging = errors.New("bucket is being emptied")
+
+// BucketPurger empties buckets in the background before they are deleted, so that large
+// buckets do not block reconcile workers. Deletions are rate limited across all buckets.
+type BucketPurger struct {
+	Minio MinioAPI
+
+	mu      sync.Mutex
+	ctx     context.Context
+	cancel  context.CancelFunc
+	limiter *rate.Limiter
+	jobs    map[string]*purgeJob
+}
+
+type purgeJob struct {
+	mu       sync.Mutex
+	progress operatorv1.BucketPurge
+	err      error
+	cancel   context.CancelFunc
+}
+
+// NewBucketPurger returns a BucketPurger, configured through the environment
+func NewBucketPurger(api MinioAPI) (*BucketPurger, error) {
+	purgeRate, err := readPurgeRate()
+	if err != nil {
+		return nil, err
+	}
+
+	limit := rate.Inf
+	if purgeRate > 0 {
+		limit = rate.Limit(purgeRate)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	return &BucketPurger{
+		Minio:   api,
+		ctx:     ctx,
+		cancel:  cancel,
+		limiter: rate.NewLimiter(limit, emptyBatchSize),
+		jobs:    map[string]*purgeJob{},
+	}, nil
+}
+
+// Start implements manager.Runnable, stopping all purges when the manager stops
+func (p *BucketPurger) Start(ctx context.Context) error {
+	<-ctx.Done()
+	p.cancel()
+	return nil
+}
+
+// Purge starts emptying a bucket unless it is already being emptied, and returns the progress
+// so far. Failed or completed purges are started again, resuming from the given progress.
+// The error of a failed purge is returned as well.
+func (p *BucketPurger) Purge(bucket string, previous *operatorv1.BucketPurge) (operatorv1.BucketPurge, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	job, found := p.jobs[bucket]
+	if found {
+		job.mu.Lock()
+		progress, err := *job.progress.DeepCopy(), job.err
+		job.mu.Unlock()
+
+		if progress.Phase == phaseRunning {
+			return progress, nil
+		}
+		if progress.Phase == phaseFailed {
+			// Report the failure once, the purge is started again on the next call
+			delete(p.jobs, bucket)
+			return progress, err
+		}
+		previous = &progress
+	}
+
+	now := metav1.Now()
+	job = &purgeJob{progress: operatorv1.BucketPurge{Phase: phaseRunning, StartTime: &now}}
+	if previous != nil {
+		job.progress.Deleted = previous.Deleted
+		job.progress.Errors = previous.Errors
+		if previous.StartTime != nil {
+			job.progress.StartTime = previous.StartTime
+		}
+	}
+
+	ctx, cancel := context.WithCancel(p.ctx)
+	job.cancel = cancel
+	p.jobs[bucket] = job
+
+	go p.run(ctx, bucket, job)
+
+	return *job.progress.DeepCopy(), nil
+}
+
+// Forget stops emptying a bucket and discards its progress
+func (p *BucketPurger) Forget(bucket string) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+
+	if job, found := p.jobs[bucket]; found {
+		job.cancel()
+		delete(p.jobs, bucket)
+	}
+}
+
+func (p *BucketPurger) run(ctx context.Context, bucket string, job *purgeJob) {
+	logger := log.Log.WithName("bucket-purger").WithValues("bucket", bucket)
+	logger.Info("Emptying bucket")
+
+	if remaining, err := p.estimateObjects(ctx, bucket); err == nil {
+		job.mu.Lock()
+		job.progress.Remaining = &remaining
+		job.mu.Unlock()
+	}
+
+	failedBatches := 0
+	for {
+		if err := p.limiter.WaitN(ctx, emptyBatchSize); err != nil {
+			return
+		}
+
+		listed, errs := p.removeBatch(ctx, bucket)
+		if ctx.Err() != nil {
+			return
+		}
+		removed := int64(listed - len(errs))
+		if removed < 0 {
+			removed = 0
+		}
+
+		job.mu.Lock()
+		job.progress.Deleted += removed
+		if job.progress.Remaining != nil {
+			*job.progress.Remaining -= removed
+			if *job.progress.Remaining < 0 {
+				*job.progress.Remaining = 0
+			}
+		}
+		if len(errs) > 0 {
+			job.progress.Errors += int64(len(errs))
+			job.err = errs[len(errs)-1]
+			job.progress.LastError = job.err.Error()
+		}
+
+		if listed == 0 && len(errs) == 0 {
+			var remaining int64
+			job.progress.Phase = phaseCompleted
+			job.progress.Remaining = &remaining
+			job.mu.Unlock()
+			logger.Info("Bucket emptied")
+			return
+		}
+
+		if removed == 0 {
+			failedBatches++
+		} else {
+			failedBatches = 0
+		}
+		if failedBatches >= maxFailedPurgeBatches {
+			job.progress.Phase = phaseFailed
+			job.mu.Unlock()
+			logger.Error(job.err, "Failed to empty bucket")
+			return
+		}
+		job.mu.Unlock()
+	}
+}
+
+// Remove up to emptyBatchSize objects, including their versions, from a bucket. Returns the
+// number of objects listed and an error for each one that could not be removed.
+func (p *BucketPurger) removeBatch(ctx context.Context, bucket string) (int, []error) {
+	batchCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	listOpts := minio.ListObjectsOptions{
+		Recursive:    true,
+		WithVersions: true,
+		MaxKeys:      emptyBatchSize,
+	}
+	objectsCh := p.Minio.ListObjects(batchCtx, bucket, listOpts)
+
+	listed := 0
+	var listErr error
+	batchCh := make(chan minio.ObjectInfo)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		defer close(batchCh)
+		for listed < emptyBatchSize {
+			object, ok := <-objectsCh
+			if !ok {
+				return
+			}
+			if object.Err != nil {
+				listErr = object.Err
+				return
+			}
+			select {
+			case batchCh <- object:
+				listed++
+			case <-batchCtx.Done():
+				return
+			}
+		}
+	}()
+
+	errs := p.Minio.RemoveObjects(batchCtx, bucket, batchCh)
+	cancel()
+	<-done
+	for range objectsCh {
+		// The rest of the listing is cut short by the cancellation
+	}
+	if listErr != nil {
+		errs = append(errs, listErr)
+	}
+
+	return listed, errs
+}
+
+// Estimate the objects and versions in a bucket from MinIO's usage data
+func (p *BucketPurger) estimateObjects(ctx context.Context, bucket string) (int64, error) {
+	dataUsage, err := p.Minio.DataUsageInfo(ctx)
+	if err != nil {
+		return 0, err
+	}
+
+	usage, found := dataUsage.BucketsUsage[bucket]
+	if !found {
+		return 0, fmt.Errorf("no usage data for bucket %s", bucket)
+	}
+	if usage.VersionsCount == 0 {
+		return int64(usage.ObjectsCount), nil
+	}
+
+	return int64(usage.VersionsCount + usage.DeleteMarkersCount), nil
+}
+
+func readPurgeRate() (int, error) {
+	purgeRate := defaultPurgeRate
+
+	purgeRateString, found := os.LookupEnv(envPurgeRate)
+	if found {
+		purgeRateParsed, err := strconv.Atoi(purgeRateString)
+		if err != nil || purgeRateParsed < 0 {
+			return 0, fmt.Errorf("%s must be a non-negative integer", envPurgeRate)
+		}
+		purgeRate = purgeRateParsed
+	}
+
+	return purgeRate, nil
+}
@@ -0,0 +1,381 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"slices"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const accessModeAnnotation = "minio.scc-digitalhub.github.io/access-mode"
+
+// Access modes
+const (
+	accessModeReadOnly  = "ro"
+	accessModeReadWrite = "rw"
+	accessModeAdmin     = "admin"
+)
+
+// Keys of the generated credentials Secret
+const (
+	secretAccessKeyIDKey     = "AWS_ACCESS_KEY_ID"
+	secretSecretAccessKeyKey = "AWS_SECRET_ACCESS_KEY"
+	secretEndpointKey        = "AWS_ENDPOINT_URL"
+	secretBucketKey          = "BUCKET_NAME"
+)
+
+// BucketAccessReconciler reconciles a BucketAccess object
+type BucketAccessReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+}
+
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=bucketaccesses,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=bucketaccesses/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=bucketaccesses/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update;patch;delete
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+// The Bucket, Policy and User children do the actual work on MinIO, this
+// reconciler only keeps them and the credentials Secret in line with the spec.
+func (r *BucketAccessReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr := &operatorv1.BucketAccess{}
+	err := r.Get(ctx, req.NamespacedName, cr)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// If the custom resource is not found, it usually means that it was deleted or not created
+			log.Info("resource not found; ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		log.Error(err, "Failed to get resource")
+		return ctrl.Result{}, err
+	}
+
+	// If status is unknown, set Creating
+	if cr.Status.State == "" {
+		log.Info("State unspecified, updating to creating")
+		cr.Status.State = typeCreating
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Children are owned by the resource and garbage collected with it
+	if cr.GetDeletionTimestamp() != nil {
+		log.Info("Resource marked to be deleted")
+		return ctrl.Result{}, nil
+	}
+
+	secret, err := r.reconcileSecret(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to reconcile credentials secret")
+		return setBucketAccessErrorState(r, ctx, cr, err)
+	}
+
+	bucket, err := r.reconcileBucket(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to reconcile bucket")
+		return setBucketAccessErrorState(r, ctx, cr, err)
+	}
+
+	policy, err := r.reconcilePolicy(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to reconcile policy")
+		return setBucketAccessErrorState(r, ctx, cr, err)
+	}
+
+	user, err := r.reconcileUser(ctx, cr, policy, secret)
+	if err != nil {
+		log.Error(err, "Failed to reconcile user")
+		return setBucketAccessErrorState(r, ctx, cr, err)
+	}
+
+	// Summarize the state of the children
+	state, message := typeReady, ""
+	for _, child := range []struct {
+		kind    string
+		state   string
+		message string
+	}{
+		{"Bucket", bucket.Status.State, bucket.Status.Message},
+		{"Policy", policy.Status.State, policy.Status.Message},
+		{"User", user.Status.State, user.Status.Message},
+	} {
+		if child.state == typeError {
+			state = typeError
+			message = fmt.Sprintf("%s: %s", child.kind, child.message)
+			break
+		}
+		if child.state != typeReady {
+			state = typeCreating
+		}
+	}
+
+	if cr.Status.State != state || cr.Status.Message != message || cr.Status.SecretName != secret.Name {
+		cr.Status.State = state
+		cr.Status.Message = message
+		cr.Status.SecretName = secret.Name
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *BucketAccessReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1.BucketAccess{}).
+		// Buckets are shared, enqueue every access holding them
+		Watches(&source.Kind{Type: &operatorv1.Bucket{}}, &handler.EnqueueRequestForOwner{OwnerType: &operatorv1.BucketAccess{}}).
+		Owns(&operatorv1.Policy{}).
+		Owns(&operatorv1.User{}).
+		Owns(&corev1.Secret{}).
+		Complete(r)
+}
+
+// Create or update the Secret holding the generated credentials, keeping any secret key already generated
+func (r *BucketAccessReconciler) reconcileSecret(ctx context.Context, cr *operatorv1.BucketAccess) (*corev1.Secret, error) {
+	endpoint, err := getEndpointURL()
+	if err != nil {
+		return nil, err
+	}
+
+	secretName := cr.Spec.SecretName
+	if secretName == "" {
+		secretName = cr.Name + "-credentials"
+	}
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: cr.Namespace}}
+	_, err = controllerutil.CreateOrUpdate(ctx, r.Client, secret, func() error {
+		if secret.Data == nil {
+			secret.Data = map[string][]byte{}
+		}
+		if len(secret.Data[secretSecretAccessKeyKey]) == 0 {
+			secretKey, err := generateSecretKey()
+			if err != nil {
+				return err
+			}
+			secret.Data[secretSecretAccessKeyKey] = []byte(secretKey)
+		}
+		secret.Data[secretAccessKeyIDKey] = []byte(bucketAccessName(cr))
+		secret.Data[secretEndpointKey] = []byte(endpoint)
+		secret.Data[secretBucketKey] = []byte(cr.Spec.BucketName)
+
+		return controllerutil.SetControllerReference(cr, secret, r.Scheme)
+	})
+
+	return secret, err
+}
+
+// Bucket children are shared by all the accesses to a bucket in the namespace, each one holding an
+// owner reference so that the bucket is only deleted along with the last of them
+func (r *BucketAccessReconciler) reconcileBucket(ctx context.Context, cr *operatorv1.BucketAccess) (*operatorv1.Bucket, error) {
+	if err := r.checkSharedQuota(ctx, cr); err != nil {
+		return nil, err
+	}
+
+	bucket := &operatorv1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: bucketAccessBucketName(cr), Namespace: cr.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, bucket, func() error {
+		bucket.Spec.Name = cr.Spec.BucketName
+		bucket.Spec.Quota = cr.Spec.Quota
+
+		return controllerutil.SetOwnerReference(cr, bucket, r.Scheme)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return bucket, r.releaseBuckets(ctx, cr, bucket.Name)
+}
+
+// Check that the other accesses to the bucket in the namespace ask for the same quota
+func (r *BucketAccessReconciler) checkSharedQuota(ctx context.Context, cr *operatorv1.BucketAccess) error {
+	accesses := &operatorv1.BucketAccessList{}
+	if err := r.List(ctx, accesses, client.InNamespace(cr.Namespace)); err != nil {
+		return err
+	}
+
+	for _, access := range accesses.Items {
+		if access.UID == cr.UID || access.Spec.BucketName != cr.Spec.BucketName || access.DeletionTimestamp != nil {
+			continue
+		}
+		if !equalQuotas(access.Spec.Quota, cr.Spec.Quota) {
+			return fmt.Errorf("bucket %s is shared with bucket access %s, which sets a different quota", cr.Spec.BucketName, access.Name)
+		}
+	}
+
+	return nil
+}
+
+// Drop the owner reference of the resource from the buckets it no longer accesses, deleting
+// those left without any other access
+func (r *BucketAccessReconciler) releaseBuckets(ctx context.Context, cr *operatorv1.BucketAccess, current string) error {
+	buckets := &operatorv1.BucketList{}
+	if err := r.List(ctx, buckets, client.InNamespace(cr.Namespace)); err != nil {
+		return err
+	}
+
+	for i := range buckets.Items {
+		bucket := &buckets.Items[i]
+		if bucket.Name == current {
+			continue
+		}
+
+		owners := slices.DeleteFunc(slices.Clone(bucket.OwnerReferences), func(owner metav1.OwnerReference) bool {
+			return owner.UID == cr.UID
+		})
+		if len(owners) == len(bucket.OwnerReferences) {
+			continue
+		}
+
+		var err error
+		if len(owners) == 0 {
+			err = r.Delete(ctx, bucket)
+		} else {
+			bucket.OwnerReferences = owners
+			err = r.Update(ctx, bucket)
+		}
+		if client.IgnoreNotFound(err) != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (r *BucketAccessReconciler) reconcilePolicy(ctx context.Context, cr *operatorv1.BucketAccess) (*operatorv1.Policy, error) {
+	policy := &operatorv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: cr.Name, Namespace: cr.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, policy, func() error {
+		accessMode := bucketAccessMode(cr)
+
+		metav1.SetMetaDataAnnotation(&policy.ObjectMeta, accessModeAnnotation, accessMode)
+		policy.Spec.Content = bucketAccessPolicyContent(cr.Spec.BucketName, accessMode)
+		policy.Spec.Name = bucketAccessName(cr)
+
+		return controllerutil.SetControllerReference(cr, policy, r.Scheme)
+	})
+
+	return policy, err
+}
+
+func (r *BucketAccessReconciler) reconcileUser(ctx context.Context, cr *operatorv1.BucketAccess, policy *operatorv1.Policy, secret *corev1.Secret) (*operatorv1.User, error) {
+	user := &operatorv1.User{ObjectMeta: metav1.ObjectMeta{Name: cr.Name, Namespace: cr.Namespace}}
+	_, err := controllerutil.CreateOrUpdate(ctx, r.Client, user, func() error {
+		user.Spec.AccessKey = bucketAccessName(cr)
+		user.Spec.SecretKey = ""
+		user.Spec.SecretKeyRef = &corev1.SecretKeySelector{
+			LocalObjectReference: corev1.LocalObjectReference{Name: secret.Name},
+			Key:                  secretSecretAccessKeyKey,
+		}
+		user.Spec.Policies = []string{policy.Spec.Name}
+		if user.Spec.AccountStatus == "" {
+			user.Spec.AccountStatus = "enabled"
+		}
+
+		return controllerutil.SetControllerReference(cr, user, r.Scheme)
+	})
+
+	return user, err
+}
+
+func setBucketAccessErrorState(r *BucketAccessReconciler, ctx context.Context, cr *operatorv1.BucketAccess, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr.Status.State = typeError
+	cr.Status.Message = err.Error()
+
+	if err := r.Status().Update(ctx, cr); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, err
+}
+
+func bucketAccessMode(cr *operatorv1.BucketAccess) string {
+	if cr.Spec.AccessMode == "" {
+		return accessModeReadWrite
+	}
+	return cr.Spec.AccessMode
+}
+
+// Name of the user and of the policy in MinIO, unique to the resource so that accesses to the same
+// bucket do not share them, and stable across changes of the access mode and restores from backups
+func bucketAccessName(cr *operatorv1.BucketAccess) string {
+	hash := sha256.Sum256([]byte(cr.Namespace + "/" + cr.Name))
+	return "ba-" + hex.EncodeToString(hash[:8])
+}
+
+// Name of the Bucket child shared by the accesses to a bucket
+func bucketAccessBucketName(cr *operatorv1.BucketAccess) string {
+	return "ba-" + cr.Spec.BucketName
+}
+
+func equalQuotas(a, b *resource.Quantity) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Cmp(*b) == 0
+}
+
+// Render the policy granting the given access mode on a bucket
+func bucketAccessPolicyContent(bucketName string, accessMode string) string {
+	bucketActions := `"s3:GetBucketLocation", "s3:ListBucket"`
+	objectActions := `"s3:GetObject"`
+
+	switch accessMode {
+	case accessModeReadWrite:
+		bucketActions = `"s3:GetBucketLocation", "s3:ListBucket", "s3:ListBucketMultipartUploads"`
+		objectActions = `"s3:GetObject", "s3:PutObject", "s3:DeleteObject", "s3:AbortMultipartUpload", "s3:ListMultipartUploadParts"`
+	case accessModeAdmin:
+		bucketActions = `"s3:*"`
+		objectActions = `"s3:*"`
+	}
+
+	return fmt.Sprintf(`{
+  "Version": "2012-10-17",
+  "Statement": [
+    {
+      "Effect": "Allow",
+      "Action": [%s],
+      "Resource": ["arn:aws:s3:::%s"]
+    },
+    {
+      "Effect": "Allow",
+      "Action": [%s],
+      "Resource": ["arn:aws:s3:::%s/*"]
+    }
+  ]
+}`, bucketActions, bucketName, objectActions, bucketName)
+}
@@ -0,0 +1,195 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func newTestBucketAccess(name string) *operatorv1.BucketAccess {
+	return &operatorv1.BucketAccess{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default", UID: types.UID("uid-" + name)},
+		Spec:       operatorv1.BucketAccessSpec{BucketName: "test-bucket", AccessMode: accessModeReadOnly},
+		Status:     operatorv1.BucketAccessStatus{State: typeCreating},
+	}
+}
+
+func newTestBucketAccessReconciler(t *testing.T, objs ...client.Object) *BucketAccessReconciler {
+	t.Setenv(envEndpoint, "minio.example.com:9000")
+	t.Setenv(envAccessKeyID, "operator")
+	t.Setenv(envSecretAccessKey, "operator-secret")
+	t.Cleanup(resetClients)
+
+	c := newFakeClient(t, objs...)
+	return &BucketAccessReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+}
+
+func TestBucketAccessReconcileCreate(t *testing.T) {
+	cr := newTestBucketAccess("test-access")
+	other := newTestBucketAccess("other-access")
+	r := newTestBucketAccessReconciler(t, cr, other)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, other, false)
+
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-access-credentials", Namespace: "default"}}
+	refetch(t, r.Client, secret)
+	user := &operatorv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test-access", Namespace: "default"}}
+	refetch(t, r.Client, user)
+	policy := &operatorv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "test-access", Namespace: "default"}}
+	refetch(t, r.Client, policy)
+
+	accessKey := string(secret.Data[secretAccessKeyIDKey])
+	ref := user.Spec.SecretKeyRef
+	if user.Spec.AccessKey != accessKey || user.Spec.SecretKey != "" || ref == nil || ref.Name != secret.Name || ref.Key != secretSecretAccessKeyKey {
+		t.Fatalf("expected user to reference the credentials of the secret, got %+v", user.Spec)
+	}
+	if len(user.Spec.Policies) != 1 || user.Spec.Policies[0] != policy.Spec.Name {
+		t.Fatalf("expected user to hold policy %s, got %v", policy.Spec.Name, user.Spec.Policies)
+	}
+	if string(secret.Data[secretEndpointKey]) != "http://minio.example.com:9000" {
+		t.Fatalf("unexpected endpoint %s", secret.Data[secretEndpointKey])
+	}
+
+	// Accesses to the same bucket do not share users nor policies
+	otherUser := &operatorv1.User{ObjectMeta: metav1.ObjectMeta{Name: "other-access", Namespace: "default"}}
+	refetch(t, r.Client, otherUser)
+	if otherUser.Spec.AccessKey == user.Spec.AccessKey || otherUser.Spec.Policies[0] == policy.Spec.Name {
+		t.Fatalf("expected distinct names, got %s for both", user.Spec.AccessKey)
+	}
+
+	// The bucket is shared, and deleted along with the last access only
+	bucket := &operatorv1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: "ba-test-bucket", Namespace: "default"}}
+	refetch(t, r.Client, bucket)
+	if bucket.Spec.Name != "test-bucket" || len(bucket.OwnerReferences) != 2 || metav1.GetControllerOf(bucket) != nil {
+		t.Fatalf("expected bucket owned by both accesses, got %+v", bucket.OwnerReferences)
+	}
+}
+
+func TestBucketAccessNameRestored(t *testing.T) {
+	cr := newTestBucketAccess("test-access")
+	restored := cr.DeepCopy()
+	restored.UID = "restored"
+
+	// Restoring a backup keeps the user and the policy in MinIO
+	if bucketAccessName(cr) != bucketAccessName(restored) {
+		t.Fatalf("expected the same name, got %s and %s", bucketAccessName(cr), bucketAccessName(restored))
+	}
+}
+
+func TestBucketAccessReconcileQuotaConflict(t *testing.T) {
+	quota := resource.MustParse("10Gi")
+	cr := newTestBucketAccess("test-access")
+	cr.Spec.Quota = &quota
+	other := newTestBucketAccess("other-access")
+	r := newTestBucketAccessReconciler(t, cr, other)
+
+	// Accesses sharing the bucket cannot set different quotas
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected error state, got %+v", cr.Status)
+	}
+	bucket := &operatorv1.Bucket{}
+	err := r.Get(context.Background(), client.ObjectKey{Name: "ba-test-bucket", Namespace: "default"}, bucket)
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no bucket to be created, got %v", err)
+	}
+}
+
+func TestBucketAccessReconcileBucketChange(t *testing.T) {
+	cr := newTestBucketAccess("test-access")
+	other := newTestBucketAccess("other-access")
+	r := newTestBucketAccessReconciler(t, cr, other)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, other, false)
+
+	for _, access := range []*operatorv1.BucketAccess{cr, other} {
+		refetch(t, r.Client, access)
+		access.Spec.BucketName = "new-bucket"
+		if err := r.Update(context.Background(), access); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// The old bucket is released by the first access, and deleted by the last one
+	oldBucket := &operatorv1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: "ba-test-bucket", Namespace: "default"}}
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, oldBucket)
+	if len(oldBucket.OwnerReferences) != 1 || oldBucket.OwnerReferences[0].UID != other.UID {
+		t.Fatalf("expected bucket to be held by the other access only, got %+v", oldBucket.OwnerReferences)
+	}
+
+	reconcileOnce(t, r, other, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(oldBucket), oldBucket)
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected old bucket to be deleted, got %v", err)
+	}
+	newBucket := &operatorv1.Bucket{ObjectMeta: metav1.ObjectMeta{Name: "ba-new-bucket", Namespace: "default"}}
+	refetch(t, r.Client, newBucket)
+	if len(newBucket.OwnerReferences) != 2 {
+		t.Fatalf("expected new bucket owned by both accesses, got %+v", newBucket.OwnerReferences)
+	}
+}
+
+func TestBucketAccessReconcileModeChange(t *testing.T) {
+	cr := newTestBucketAccess("test-access")
+	r := newTestBucketAccessReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	policy := &operatorv1.Policy{ObjectMeta: metav1.ObjectMeta{Name: "test-access", Namespace: "default"}}
+	refetch(t, r.Client, policy)
+	name := policy.Spec.Name
+
+	refetch(t, r.Client, cr)
+	cr.Spec.AccessMode = accessModeReadWrite
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+
+	// The same policy is updated, rather than a new one attached next to it
+	refetch(t, r.Client, policy)
+	if policy.Spec.Name != name || !strings.Contains(policy.Spec.Content, "s3:PutObject") {
+		t.Fatalf("expected policy %s to grant write access, got %s: %s", name, policy.Spec.Name, policy.Spec.Content)
+	}
+	user := &operatorv1.User{ObjectMeta: metav1.ObjectMeta{Name: "test-access", Namespace: "default"}}
+	refetch(t, r.Client, user)
+	if len(user.Spec.Policies) != 1 || user.Spec.Policies[0] != name {
+		t.Fatalf("expected user to hold only policy %s, got %v", name, user.Spec.Policies)
+	}
+}
+
+func TestBucketAccessReconcileDelete(t *testing.T) {
+	cr := newTestBucketAccess("test-access")
+	now := metav1.Now()
+	cr.DeletionTimestamp = &now
+	cr.Finalizers = []string{"test"}
+	r := newTestBucketAccessReconciler(t, cr)
+
+	// Children are garbage collected with the resource, not created again
+	reconcileOnce(t, r, cr, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.User{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected no user to be created, got %v", err)
+	}
+}
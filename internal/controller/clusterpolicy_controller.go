@@ -0,0 +1,121 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"slices"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const clusterPolicyFinalizer = "minio.scc-digitalhub.github.io/clusterpolicy-finalizer"
+
+// ClusterPolicyReconciler reconciles a ClusterPolicy object
+type ClusterPolicyReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Minio    MinioAPI
+}
+
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=clusterpolicies,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=clusterpolicies/status,verbs=get;update;patch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=clusterpolicies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=users,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
+
+// Reconcile is part of the main kubernetes reconciliation loop which aims to
+// move the current state of the cluster closer to the desired state.
+func (r *ClusterPolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr := &operatorv1.ClusterPolicy{}
+	err := r.Get(ctx, req.NamespacedName, cr)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// If the custom resource is not found, it usually means that it was deleted or not created
+			log.Info("resource not found; ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		log.Error(err, "Failed to get resource")
+		return ctrl.Result{}, err
+	}
+
+	return r.cannedPolicyReconciler().reconcile(ctx, r.cannedPolicy(cr))
+}
+
+func (r *ClusterPolicyReconciler) cannedPolicyReconciler() *cannedPolicyReconciler {
+	return &cannedPolicyReconciler{Client: r.Client, Recorder: r.Recorder, Minio: r.Minio}
+}
+
+func (r *ClusterPolicyReconciler) cannedPolicy(cr *operatorv1.ClusterPolicy) *cannedPolicy {
+	return &cannedPolicy{
+		obj:            cr,
+		kind:           "ClusterPolicy",
+		name:           cr.Spec.Name,
+		deletionPolicy: cr.Spec.DeletionPolicy,
+		finalizer:      clusterPolicyFinalizer,
+		status:         &cr.Status,
+		render: func(ctx context.Context) ([]byte, error) {
+			return []byte(cr.Spec.Content), nil
+		},
+		check: func(ctx context.Context) error {
+			// Policies owned by another ClusterPolicy are not written to MinIO
+			return checkNameClaim(ctx, r.Client, cr)
+		},
+		ownedElsewhere: func(ctx context.Context) (bool, error) {
+			owner, err := nameOwnedElsewhere(ctx, r.Client, cr)
+			return owner != "", err
+		},
+	}
+}
+
+// SetupWithManager sets up the controller with the Manager.
+func (r *ClusterPolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Also used by the PolicyCheck controller
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &operatorv1.ClusterPolicy{}, minioNameIndex, indexClusterPolicyName); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1.ClusterPolicy{}).
+		Watches(&source.Kind{Type: &operatorv1.User{}}, handler.EnqueueRequestsFromMapFunc(r.clusterPoliciesForUser)).
+		Complete(r)
+}
+
+// Cluster policies referenced by a User, reconciled when it changes to keep their list of users
+// current and to resume deletions waiting for the user
+func (r *ClusterPolicyReconciler) clusterPoliciesForUser(user client.Object) []reconcile.Request {
+	names := user.(*operatorv1.User).Spec.Policies
+	if len(names) == 0 {
+		return nil
+	}
+
+	policies := &operatorv1.ClusterPolicyList{}
+	if err := r.List(context.Background(), policies); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if slices.Contains(names, policy.Spec.Name) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+		}
+	}
+
+	return requests
+}
@@ -0,0 +1,99 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func newTestClusterPolicy() *operatorv1.ClusterPolicy {
+	return &operatorv1.ClusterPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy"},
+		Spec: operatorv1.ClusterPolicySpec{
+			Name:    "test-policy",
+			Content: testPolicyContent,
+		},
+	}
+}
+
+func newTestClusterPolicyReconciler(t *testing.T, cr *operatorv1.ClusterPolicy, objs ...client.Object) (*ClusterPolicyReconciler, *fakeMinio) {
+	minio := newFakeMinio()
+	c := newFakeClient(t, append(objs, cr)...)
+	return &ClusterPolicyReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+		Minio:    minio,
+	}, minio
+}
+
+func TestClusterPolicyReconcileCreate(t *testing.T) {
+	cr := newTestClusterPolicy()
+	user := newTestUser("test-policy")
+	user.Namespace = "tenant"
+	r, minio := newTestClusterPolicyReconciler(t, cr, user)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s: %s", typeReady, cr.Status.State, cr.Status.Message)
+	}
+	if _, found := minio.policies["test-policy"]; !found {
+		t.Fatal("expected policy to be created")
+	}
+	if !slices.Equal(cr.Status.Users, []string{"tenant/test-user"}) {
+		t.Fatalf("unexpected users %v", cr.Status.Users)
+	}
+
+	requests := r.clusterPoliciesForUser(user)
+	if len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
+
+func TestClusterPolicyReconcileDeleteInUse(t *testing.T) {
+	cr := newTestClusterPolicy()
+	cr.Status.State = typeCreating
+	user := newTestUser("test-policy")
+	r, minio := newTestClusterPolicyReconciler(t, cr, user)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected deletion to be blocked, got %+v", cr.Status)
+	}
+
+	refetch(t, r.Client, user)
+	user.Spec.Policies = nil
+	if err := r.Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.policies["test-policy"]; found {
+		t.Fatal("expected policy to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.ClusterPolicy{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
@@ -5,9 +5,17 @@
 package controller
 
 import (
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
 	"fmt"
+	"math/big"
+	"net/http"
 	"os"
 	"strconv"
+	"sync"
+	"time"
 
 	"github.com/minio/madmin-go/v3"
 	"github.com/minio/minio-go/v7"
@@ -15,13 +23,24 @@ import (
 )
 
 const genericStatusUpdateFailedMessage = "failed to update resource status"
-const failedToObtainClientMessage = "Failed to obtain MinIO client"
-const failedToObtainAdminClientMessage = "Failed to obtain MinIO admin client"
 
 const envEndpoint = "MINIO_ENDPOINT"
 const envAccessKeyID = "MINIO_ACCESS_KEY_ID"
 const envSecretAccessKey = "MINIO_SECRET_ACCESS_KEY"
+const envAccessKeyIDFile = "MINIO_ACCESS_KEY_ID_FILE"
+const envSecretAccessKeyFile = "MINIO_SECRET_ACCESS_KEY_FILE"
+const envAuthMode = "MINIO_AUTH_MODE"
+const envSTSEndpoint = "MINIO_STS_ENDPOINT"
+const envSTSTokenFile = "MINIO_STS_TOKEN_FILE"
+const envSTSRoleARN = "MINIO_STS_ROLE_ARN"
 const envUseSSL = "MINIO_USE_SSL"
+const envCAFile = "MINIO_CA_FILE"
+const envClientCertFile = "MINIO_CLIENT_CERT_FILE"
+const envClientKeyFile = "MINIO_CLIENT_KEY_FILE"
+const envInsecureSkipVerify = "MINIO_INSECURE_SKIP_VERIFY"
+const envOperationTimeout = "MINIO_OPERATION_TIMEOUT"
+
+const defaultOperationTimeout = 30 * time.Second
 
 // Status
 const (
@@ -29,50 +48,145 @@ const (
 	typeReady    = "Ready"
 	typeUpdating = "Updating"
 	typeDegraded = "Degraded"
+	typeDeleting = "Deleting"
 	typeError    = "Error"
 )
 
 var minioEndpoint string
 var accessKeyID string
 var secretAccessKey string
+var accessKeyIDFile string
+var secretAccessKeyFile string
+var authMode string
+var stsEndpoint string
+var stsTokenFile string
+var stsRoleARN string
 var useSSL bool
+var caFile string
+var clientCertFile string
+var clientKeyFile string
+var insecureSkipVerify bool
+var operationTimeout = defaultOperationTimeout
 
+// Clients are shared by all reconcilers and rebuilt after resetClients
+var clientsMutex sync.Mutex
+var minioTransport *http.Transport = nil
+var minioCredentials *credentials.Credentials = nil
 var minioClient *minio.Client = nil
 var minioAdminClient *madmin.AdminClient = nil
 
 // Get MinIO client
 func getClient() (*minio.Client, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
 	if minioClient != nil {
 		return minioClient, nil
 	}
 
-	err := initializeEnvs()
+	transport, err := sharedTransport()
 	if err != nil {
 		return nil, err
 	}
 
-	minioClient, err := minio.New(minioEndpoint, &minio.Options{
-		Creds:  credentials.NewStaticV4(accessKeyID, secretAccessKey, ""),
-		Secure: useSSL,
+	if minioCredentials == nil {
+		minioCredentials = newCredentials(transport)
+	}
+
+	client, err := minio.New(minioEndpoint, &minio.Options{
+		Creds:     minioCredentials,
+		Secure:    useSSL,
+		Transport: transport,
 	})
+	if err != nil {
+		return nil, err
+	}
 
-	return minioClient, err
+	minioClient = client
+	return minioClient, nil
 }
 
 // Get MinIO Admin client
 func getAdminClient() (*madmin.AdminClient, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
 	if minioAdminClient != nil {
 		return minioAdminClient, nil
 	}
 
+	transport, err := sharedTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	if minioCredentials == nil {
+		minioCredentials = newCredentials(transport)
+	}
+
+	adminClient, err := madmin.NewWithOptions(minioEndpoint, &madmin.Options{
+		Creds:     minioCredentials,
+		Secure:    useSSL,
+		Transport: transport,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	minioAdminClient = adminClient
+	return minioAdminClient, nil
+}
+
+// Get a MinIO client authenticating with the given credentials instead of the operator's
+func getClientWithCredentials(accessKey string, secretKey string) (*minio.Client, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	transport, err := sharedTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	return minio.New(minioEndpoint, &minio.Options{
+		Creds:     credentials.NewStaticV4(accessKey, secretKey, ""),
+		Secure:    useSSL,
+		Transport: transport,
+	})
+}
+
+// Load the configuration and build the transport shared by all clients, on first use
+// and after resetClients. Must be called with clientsMutex held.
+func sharedTransport() (*http.Transport, error) {
+	if minioTransport != nil {
+		return minioTransport, nil
+	}
+
 	err := initializeEnvs()
 	if err != nil {
 		return nil, err
 	}
 
-	minioAdminClient, err := madmin.New(minioEndpoint, accessKeyID, secretAccessKey, useSSL)
+	transport, err := newTransport()
+	if err != nil {
+		return nil, err
+	}
+
+	minioTransport = transport
+	return minioTransport, nil
+}
+
+// Drop the cached clients, transport and credentials, so that they are built again on next use
+func resetClients() {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
 
-	return minioAdminClient, err
+	if minioCredentials != nil {
+		minioCredentials.Expire()
+	}
+	minioTransport = nil
+	minioCredentials = nil
+	minioClient = nil
+	minioAdminClient = nil
 }
 
 func initializeEnvs() error {
@@ -85,18 +199,42 @@ func initializeEnvs() error {
 		}
 	}
 
-	if accessKeyID == "" {
-		accessKeyID, found = os.LookupEnv(envAccessKeyID)
-		if !found {
-			return fmt.Errorf("%s must be set", envAccessKeyID)
-		}
+	authMode = os.Getenv(envAuthMode)
+	if authMode == "" {
+		authMode = authModeStatic
 	}
 
-	if secretAccessKey == "" {
-		secretAccessKey, found = os.LookupEnv(envSecretAccessKey)
-		if !found {
-			return fmt.Errorf("%s must be set", envSecretAccessKey)
+	switch authMode {
+	case authModeStatic:
+		// Credentials are read either from files, which are reloaded when they change, or from the environment
+		accessKeyIDFile = os.Getenv(envAccessKeyIDFile)
+		secretAccessKeyFile = os.Getenv(envSecretAccessKeyFile)
+		if (accessKeyIDFile == "") != (secretAccessKeyFile == "") {
+			return fmt.Errorf("%s and %s must be set together", envAccessKeyIDFile, envSecretAccessKeyFile)
+		}
+
+		if accessKeyID == "" && accessKeyIDFile == "" {
+			accessKeyID, found = os.LookupEnv(envAccessKeyID)
+			if !found {
+				return fmt.Errorf("%s must be set", envAccessKeyID)
+			}
+		}
+
+		if secretAccessKey == "" && secretAccessKeyFile == "" {
+			secretAccessKey, found = os.LookupEnv(envSecretAccessKey)
+			if !found {
+				return fmt.Errorf("%s must be set", envSecretAccessKey)
+			}
 		}
+	case authModeWebIdentity, authModeClientGrants:
+		stsEndpoint = os.Getenv(envSTSEndpoint)
+		stsRoleARN = os.Getenv(envSTSRoleARN)
+		stsTokenFile = os.Getenv(envSTSTokenFile)
+		if stsTokenFile == "" {
+			stsTokenFile = defaultSTSTokenFile
+		}
+	default:
+		return fmt.Errorf("%s must be one of %s, %s or %s", envAuthMode, authModeStatic, authModeWebIdentity, authModeClientGrants)
 	}
 
 	useSSLString, found := os.LookupEnv(envUseSSL)
@@ -108,5 +246,124 @@ func initializeEnvs() error {
 		useSSL = useSSLParsed
 	}
 
+	caFile = os.Getenv(envCAFile)
+	clientCertFile = os.Getenv(envClientCertFile)
+	clientKeyFile = os.Getenv(envClientKeyFile)
+	if (clientCertFile == "") != (clientKeyFile == "") {
+		return fmt.Errorf("%s and %s must be set together", envClientCertFile, envClientKeyFile)
+	}
+
+	insecureSkipVerifyString, found := os.LookupEnv(envInsecureSkipVerify)
+	if found {
+		insecureSkipVerifyParsed, err := strconv.ParseBool(insecureSkipVerifyString)
+		if err != nil {
+			return fmt.Errorf("%s must be either true or false", envInsecureSkipVerify)
+		}
+		insecureSkipVerify = insecureSkipVerifyParsed
+	}
+
+	operationTimeoutString, found := os.LookupEnv(envOperationTimeout)
+	if found {
+		operationTimeoutParsed, err := time.ParseDuration(operationTimeoutString)
+		if err != nil || operationTimeoutParsed < 0 {
+			return fmt.Errorf("%s must be a valid duration, e.g. 30s", envOperationTimeout)
+		}
+		operationTimeout = operationTimeoutParsed
+	}
+
 	return nil
 }
+
+// Bound a single MinIO operation by the configured timeout, a timeout of 0 disables it.
+// The operation is cancelled as well when ctx is, e.g. on shutdown.
+func operationContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if operationTimeout == 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, operationTimeout)
+}
+
+// Build the HTTP transport shared by the S3 and admin clients, trusting the
+// configured CA bundle and presenting the client certificate, if any
+func newTransport() (*http.Transport, error) {
+	transport, err := minio.DefaultTransport(useSSL)
+	if err != nil {
+		return nil, err
+	}
+
+	if !useSSL {
+		return transport, nil
+	}
+
+	if transport.TLSClientConfig == nil {
+		transport.TLSClientConfig = &tls.Config{MinVersion: tls.VersionTLS12}
+	}
+
+	if caFile != "" {
+		caBundle, err := os.ReadFile(caFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle from %s: %w", envCAFile, err)
+		}
+
+		rootCAs := transport.TLSClientConfig.RootCAs
+		if rootCAs == nil {
+			rootCAs, err = x509.SystemCertPool()
+			if err != nil {
+				rootCAs = x509.NewCertPool()
+			}
+		}
+		if !rootCAs.AppendCertsFromPEM(caBundle) {
+			return nil, fmt.Errorf("no valid certificates found in %s", caFile)
+		}
+		transport.TLSClientConfig.RootCAs = rootCAs
+	}
+
+	if clientCertFile != "" {
+		certificate, err := tls.LoadX509KeyPair(clientCertFile, clientKeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load client certificate: %w", err)
+		}
+		transport.TLSClientConfig.Certificates = []tls.Certificate{certificate}
+	}
+
+	transport.TLSClientConfig.InsecureSkipVerify = insecureSkipVerify
+
+	return transport, nil
+}
+
+// Get the URL applications should use to reach MinIO, loading the configuration on first use
+func getEndpointURL() (string, error) {
+	clientsMutex.Lock()
+	defer clientsMutex.Unlock()
+
+	if _, err := sharedTransport(); err != nil {
+		return "", err
+	}
+
+	return endpointURL(), nil
+}
+
+// Build the URL applications should use to reach MinIO. Must be called with clientsMutex held.
+func endpointURL() string {
+	if useSSL {
+		return "https://" + minioEndpoint
+	}
+	return "http://" + minioEndpoint
+}
+
+// Generate a random secret key suitable for a MinIO user
+func generateSecretKey() (string, error) {
+	const charset = "abcdefghijklmnopqrstuvwxyzABCDEFGHIJKLMNOPQRSTUVWXYZ0123456789"
+	const length = 40
+
+	key := make([]byte, length)
+	for i := range key {
+		n, err := rand.Int(rand.Reader, big.NewInt(int64(len(charset))))
+		if err != nil {
+			return "", err
+		}
+		key[i] = charset[n.Int64()]
+	}
+
+	return string(key), nil
+}
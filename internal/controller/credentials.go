@@ -0,0 +1,160 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// Authentication modes of the operator
+const (
+	authModeStatic       = "static"
+	authModeWebIdentity  = "webidentity"
+	authModeClientGrants = "clientgrants"
+)
+
+// Token of the pod's service account, prefer a projected token with MinIO as audience
+const defaultSTSTokenFile = "/var/run/secrets/kubernetes.io/serviceaccount/token"
+
+// Error codes returned by MinIO when the operator's own credentials are rejected
+var authErrorCodes = []string{
+	"InvalidAccessKeyId",
+	"SignatureDoesNotMatch",
+	"ExpiredToken",
+	"InvalidToken",
+}
+
+// fileCredentials reads the operator credentials from files, usually a mounted Secret,
+// and reloads them whenever the files change
+type fileCredentials struct {
+	accessKeyIDFile     string
+	secretAccessKeyFile string
+
+	mu      sync.Mutex
+	modTime time.Time
+}
+
+func (f *fileCredentials) Retrieve() (credentials.Value, error) {
+	modTime, err := f.lastModified()
+	if err != nil {
+		return credentials.Value{}, err
+	}
+
+	accessKeyID, err := os.ReadFile(f.accessKeyIDFile)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to read access key from %s: %w", envAccessKeyIDFile, err)
+	}
+	secretAccessKey, err := os.ReadFile(f.secretAccessKeyFile)
+	if err != nil {
+		return credentials.Value{}, fmt.Errorf("failed to read secret key from %s: %w", envSecretAccessKeyFile, err)
+	}
+
+	f.mu.Lock()
+	f.modTime = modTime
+	f.mu.Unlock()
+
+	return credentials.Value{
+		AccessKeyID:     strings.TrimSpace(string(accessKeyID)),
+		SecretAccessKey: strings.TrimSpace(string(secretAccessKey)),
+		SignerType:      credentials.SignatureV4,
+	}, nil
+}
+
+// Credentials expire as soon as either file is modified
+func (f *fileCredentials) IsExpired() bool {
+	modTime, err := f.lastModified()
+	if err != nil {
+		return true
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return !modTime.Equal(f.modTime)
+}
+
+func (f *fileCredentials) lastModified() (time.Time, error) {
+	var modTime time.Time
+	for _, file := range []string{f.accessKeyIDFile, f.secretAccessKeyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if info.ModTime().After(modTime) {
+			modTime = info.ModTime()
+		}
+	}
+
+	return modTime, nil
+}
+
+// Build the credentials used by the operator. Static credentials are read either from files or
+// from the environment, STS credentials are short-lived and refreshed automatically when they expire.
+func newCredentials(transport http.RoundTripper) *credentials.Credentials {
+	endpoint := stsEndpoint
+	if endpoint == "" {
+		endpoint = endpointURL()
+	}
+
+	switch authMode {
+	case authModeWebIdentity:
+		return credentials.New(&credentials.STSWebIdentity{
+			Client:      &http.Client{Transport: transport},
+			STSEndpoint: endpoint,
+			RoleARN:     stsRoleARN,
+			GetWebIDTokenExpiry: func() (*credentials.WebIdentityToken, error) {
+				token, err := readSTSToken()
+				if err != nil {
+					return nil, err
+				}
+				return &credentials.WebIdentityToken{Token: token}, nil
+			},
+		})
+	case authModeClientGrants:
+		return credentials.New(&credentials.STSClientGrants{
+			Client:      &http.Client{Transport: transport},
+			STSEndpoint: endpoint,
+			GetClientGrantsTokenExpiry: func() (*credentials.ClientGrantsToken, error) {
+				token, err := readSTSToken()
+				if err != nil {
+					return nil, err
+				}
+				return &credentials.ClientGrantsToken{Token: token}, nil
+			},
+		})
+	}
+
+	if accessKeyIDFile != "" {
+		return credentials.New(&fileCredentials{
+			accessKeyIDFile:     accessKeyIDFile,
+			secretAccessKeyFile: secretAccessKeyFile,
+		})
+	}
+
+	return credentials.NewStaticV4(accessKeyID, secretAccessKey, "")
+}
+
+// Read the token exchanged for STS credentials, read again on every refresh since
+// projected service account tokens are rotated by the kubelet
+func readSTSToken() (string, error) {
+	token, err := os.ReadFile(stsTokenFile)
+	if err != nil {
+		return "", fmt.Errorf("failed to read STS token from %s: %w", stsTokenFile, err)
+	}
+
+	return strings.TrimSpace(string(token)), nil
+}
+
+// Check whether MinIO rejected the operator's credentials
+func isAuthError(err error) bool {
+	return hasErrorCode(err, authErrorCodes...)
+}
@@ -0,0 +1,244 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+	"github.com/minio/minio-go/v7"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// Error codes returned by MinIO
+const (
+	codeNoSuchBucket                 = "NoSuchBucket"
+	codeNoSuchKey                    = "NoSuchKey"
+	codeNoSuchLifecycleConfiguration = "NoSuchLifecycleConfiguration"
+	codeBucketNotEmpty               = "BucketNotEmpty"
+	codeBucketAlreadyOwnedByYou      = "BucketAlreadyOwnedByYou"
+	codeBucketAlreadyExists          = "BucketAlreadyExists"
+	codeOperationAborted             = "OperationAborted"
+	codeNoSuchUser                   = "XMinioAdminNoSuchUser"
+	codeNoSuchPolicy                 = "XMinioAdminNoSuchPolicy"
+	codePolicyChangeAlreadyApplied   = "XMinioAdminPolicyChangeAlreadyApplied"
+	codeAccessDenied                 = "AccessDenied"
+	codeInvalidBucketName            = "InvalidBucketName"
+	codeInvalidArgument              = "InvalidArgument"
+	codeAdminInvalidArgument         = "XMinioAdminInvalidArgument"
+	codeMalformedPolicy              = "MalformedPolicy"
+	codeMalformedIAMPolicy           = "XMinioMalformedIAMPolicy"
+	codeNotImplemented               = "NotImplemented"
+	codeIAMActionNotAllowed          = "XMinioIAMActionNotAllowed"
+)
+
+// Error classes, also used as reasons of the Synced condition
+type errorClass string
+
+const (
+	// The operation may succeed if attempted again, e.g. MinIO is unreachable
+	errorClassRetryable errorClass = "Retryable"
+	// The operation will keep failing until the resource is changed, e.g. invalid names or policies
+	errorClassPermanent errorClass = "Permanent"
+	// MinIO holds state conflicting with the resource, which may be resolved out of band
+	errorClassConflict errorClass = "Conflict"
+)
+
+const conditionSynced = "Synced"
+const reasonReconciled = "Reconciled"
+
+// Returned by finalizers of resources protected from deletion, until the deletion is confirmed
+var errDeletionProtected = errors.New("deletion protection is enabled")
+
+// Returned by finalizers of policies still referenced by users, until they no longer are
+var errPolicyInUse = errors.New("policy is in use")
+
+// Returned for policy contents that cannot be parsed
+var errInvalidPolicy = errors.New("invalid policy")
+
+// Returned for namespaced policies named like a cluster policy
+var errPolicyNameReserved = errors.New("policy name is reserved")
+
+// Returned for resources violating the MinioTenantPolicies of their namespace
+var errTenantPolicyViolation = errors.New("forbidden by tenant policy")
+
+// Returned for Buckets and Policies whose MinIO name is owned by a resource in another namespace,
+// and for ClusterPolicies whose name is owned by another ClusterPolicy
+var errNameClaimed = errors.New("name is owned by")
+
+// Resources in conflict are checked again after this delay, rather than with exponential backoff
+const conflictRequeueDelay = 30 * time.Second
+
+var permanentErrorCodes = []string{
+	codeInvalidBucketName,
+	codeInvalidArgument,
+	codeAdminInvalidArgument,
+	codeMalformedPolicy,
+	codeMalformedIAMPolicy,
+	codeNoSuchPolicy,
+	codeNotImplemented,
+	codeIAMActionNotAllowed,
+}
+
+var conflictErrorCodes = []string{
+	codeBucketAlreadyExists,
+	codeBucketNotEmpty,
+	codeOperationAborted,
+}
+
+// Return the code of an error returned by the S3 or admin APIs, empty for any other error
+func errorCode(err error) string {
+	var s3Err minio.ErrorResponse
+	if errors.As(err, &s3Err) {
+		return s3Err.Code
+	}
+
+	var adminErr madmin.ErrorResponse
+	if errors.As(err, &adminErr) {
+		return adminErr.Code
+	}
+
+	return ""
+}
+
+func hasErrorCode(err error, codes ...string) bool {
+	code := errorCode(err)
+	if code == "" {
+		return false
+	}
+
+	for _, c := range codes {
+		if code == c {
+			return true
+		}
+	}
+
+	return false
+}
+
+func isNoSuchBucket(err error) bool {
+	return hasErrorCode(err, codeNoSuchBucket)
+}
+
+func isBucketNotEmpty(err error) bool {
+	return hasErrorCode(err, codeBucketNotEmpty)
+}
+
+func isBucketAlreadyOwned(err error) bool {
+	return hasErrorCode(err, codeBucketAlreadyOwnedByYou)
+}
+
+func isNoSuchUser(err error) bool {
+	return hasErrorCode(err, codeNoSuchUser)
+}
+
+func isNoSuchPolicy(err error) bool {
+	return hasErrorCode(err, codeNoSuchPolicy)
+}
+
+func isPolicyChangeAlreadyApplied(err error) bool {
+	return hasErrorCode(err, codePolicyChangeAlreadyApplied)
+}
+
+// Classify an error to decide whether and when to retry. Errors without a code, such as network
+// failures, and rejected operator credentials, which may be reloaded, are retryable.
+func classifyError(err error) errorClass {
+	if errors.Is(err, errDeletionProtected) {
+		// Checked again when the confirmation is added
+		return errorClassPermanent
+	}
+	if errors.Is(err, errPolicyInUse) {
+		// Checked again when the users referencing the policy change
+		return errorClassPermanent
+	}
+	if errors.Is(err, errInvalidPolicy) {
+		// Checked again when the content is changed
+		return errorClassPermanent
+	}
+	if errors.Is(err, errPolicyNameReserved) {
+		// Checked again when the name is changed
+		return errorClassPermanent
+	}
+	if errors.Is(err, errTenantPolicyViolation) {
+		// Checked again when the tenant policies change, or after a delay
+		return errorClassConflict
+	}
+	if errors.Is(err, errNameClaimed) {
+		// Checked again after a delay, since the owner may release the name
+		return errorClassConflict
+	}
+	if hasErrorCode(err, permanentErrorCodes...) {
+		return errorClassPermanent
+	}
+	if hasErrorCode(err, conflictErrorCodes...) {
+		return errorClassConflict
+	}
+	if isAuthError(err) {
+		return errorClassRetryable
+	}
+	if hasErrorCode(err, codeAccessDenied) {
+		// Cleared by changing the policies of the operator's credentials, not the resource
+		return errorClassRetryable
+	}
+
+	// Unknown client errors from the S3 API are not going to go away by themselves
+	var s3Err minio.ErrorResponse
+	if errors.As(err, &s3Err) {
+		statusCode := s3Err.StatusCode
+		if statusCode >= http.StatusBadRequest && statusCode < http.StatusInternalServerError &&
+			statusCode != http.StatusRequestTimeout && statusCode != http.StatusTooManyRequests {
+			return errorClassPermanent
+		}
+	}
+
+	return errorClassRetryable
+}
+
+// Result of a reconcile that failed with err: retryable errors are returned so that the request
+// is requeued with exponential backoff, conflicts are checked again after a fixed delay, while
+// permanent errors are not retried until the resource changes
+func resultForError(err error) (ctrl.Result, error) {
+	switch classifyError(err) {
+	case errorClassPermanent:
+		return ctrl.Result{}, nil
+	case errorClassConflict:
+		return ctrl.Result{RequeueAfter: conflictRequeueDelay}, nil
+	}
+
+	return ctrl.Result{}, err
+}
+
+// Set the Synced condition, reporting the class of err as reason when not nil
+func setSyncedCondition(conditions *[]metav1.Condition, generation int64, err error) {
+	condition := metav1.Condition{
+		Type:               conditionSynced,
+		Status:             metav1.ConditionTrue,
+		Reason:             reasonReconciled,
+		Message:            "Resource is in sync with MinIO",
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		condition.Status = metav1.ConditionFalse
+		condition.Reason = string(classifyError(err))
+		condition.Message = err.Error()
+	}
+
+	meta.SetStatusCondition(conditions, condition)
+}
+
+// Whether a resource in error state should be reconciled again: permanent errors are only
+// retried once the spec changes, any other error on every requeue
+func shouldRetryError(conditions []metav1.Condition, generation int64) bool {
+	condition := meta.FindStatusCondition(conditions, conditionSynced)
+	if condition == nil {
+		return true
+	}
+
+	return condition.Reason != string(errorClassPermanent) || condition.ObservedGeneration != generation
+}
@@ -0,0 +1,42 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+)
+
+func TestClassifyError(t *testing.T) {
+	tests := []struct {
+		name string
+		err  error
+		want errorClass
+	}{
+		{"network", errors.New("dial tcp: connection refused"), errorClassRetryable},
+		{"server", s3Error(500, "InternalError", "We encountered an internal error, please try again."), errorClassRetryable},
+		{"throttled", s3Error(503, "SlowDown", "Please reduce your request rate."), errorClassRetryable},
+		{"credentials", s3Error(403, "InvalidAccessKeyId", "The Access Key Id you provided does not exist in our records."), errorClassRetryable},
+		{"access denied", s3Error(403, "AccessDenied", "Access Denied."), errorClassRetryable},
+		{"invalid bucket name", s3Error(400, "InvalidBucketName", "The specified bucket is not valid."), errorClassPermanent},
+		{"unknown client error", s3Error(400, "SomethingNew", "Something new."), errorClassPermanent},
+		{"malformed policy", adminError("XMinioMalformedIAMPolicy", "policy has invalid resource"), errorClassPermanent},
+		{"missing policy", adminError("XMinioAdminNoSuchPolicy", "The canned policy does not exist."), errorClassPermanent},
+		{"not empty", s3Error(409, "BucketNotEmpty", "The bucket you tried to delete is not empty"), errorClassConflict},
+		{"owned by others", s3Error(409, "BucketAlreadyExists", "The requested bucket name is not available."), errorClassConflict},
+		{"invalid content", fmt.Errorf("%w: unexpected end of JSON input", errInvalidPolicy), errorClassPermanent},
+		{"name claimed", fmt.Errorf("%w namespace team-a", errNameClaimed), errorClassConflict},
+		{"wrapped", fmt.Errorf("creating bucket: %w", s3Error(409, "BucketNotEmpty", "not empty")), errorClassConflict},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := classifyError(tt.err); got != tt.want {
+				t.Errorf("classifyError() = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
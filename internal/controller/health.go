@@ -0,0 +1,60 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"net/http"
+	"sync"
+	"time"
+
+	"sigs.k8s.io/controller-runtime/pkg/healthz"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+// minioConnectivity caches the outcome of the last MinIO connectivity check
+type minioConnectivity struct {
+	mu        sync.Mutex
+	checkedAt time.Time
+	err       error
+}
+
+// MinioReadyCheck returns a readiness check verifying that the operator can talk to MinIO.
+// Each check calls ServerInfo bounded by the given timeout, and its result is reused for cacheFor.
+func MinioReadyCheck(api MinioAPI, cacheFor time.Duration, timeout time.Duration) healthz.Checker {
+	connectivity := &minioConnectivity{}
+
+	return func(_ *http.Request) error {
+		connectivity.mu.Lock()
+		defer connectivity.mu.Unlock()
+
+		if !connectivity.checkedAt.IsZero() && time.Since(connectivity.checkedAt) < cacheFor {
+			return connectivity.err
+		}
+
+		err := checkMinioConnectivity(api, timeout)
+		if (err == nil) != (connectivity.err == nil) || connectivity.checkedAt.IsZero() {
+			logger := log.Log.WithName("minio-connectivity")
+			if err != nil {
+				logger.Error(err, "MinIO is unreachable")
+			} else {
+				logger.Info("MinIO is reachable")
+			}
+		}
+
+		connectivity.checkedAt = time.Now()
+		connectivity.err = err
+		setMinioUp(err == nil)
+
+		return err
+	}
+}
+
+func checkMinioConnectivity(api MinioAPI, timeout time.Duration) error {
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	return api.ServerInfo(ctx)
+}
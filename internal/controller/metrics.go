@@ -0,0 +1,248 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+	"github.com/prometheus/client_golang/prometheus"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const metricsNamespace = "minio_operator"
+
+// Outcomes of MinIO calls and finalizers
+const (
+	outcomeSuccess = "success"
+	outcomeError   = "error"
+)
+
+var bucketLabels = []string{"namespace", "name", "bucket"}
+
+var userLabels = []string{"namespace", "name", "access_key"}
+
+var (
+	minioRequestsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "minio_requests_total",
+		Help:      "Number of calls to the MinIO APIs, by operation and outcome.",
+	}, []string{"operation", "outcome"})
+	minioRequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "minio_request_duration_seconds",
+		Help:      "Latency of calls to the MinIO APIs, by operation and outcome.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"operation", "outcome"})
+	driftCorrectionsTotal = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: metricsNamespace,
+		Name:      "drift_corrections_total",
+		Help:      "Number of times MinIO was found out of sync with a custom resource and corrected.",
+	}, []string{"kind"})
+	minioUp = prometheus.NewGauge(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "minio_up",
+		Help:      "Whether the last connectivity check could reach MinIO (1) or not (0).",
+	})
+	finalizerDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: metricsNamespace,
+		Name:      "finalizer_duration_seconds",
+		Help:      "Time spent performing finalizer operations, by kind and outcome.",
+		Buckets:   []float64{0.1, 0.5, 1, 5, 10, 30, 60, 300, 900},
+	}, []string{"kind", "outcome"})
+)
+
+var (
+	bucketSizeBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bucket_size_bytes",
+		Help:      "Total size of the objects stored in the bucket.",
+	}, bucketLabels)
+	bucketObjects = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bucket_objects",
+		Help:      "Number of objects stored in the bucket.",
+	}, bucketLabels)
+	bucketVersions = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bucket_versions",
+		Help:      "Number of object versions stored in the bucket.",
+	}, bucketLabels)
+	bucketQuotaBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: metricsNamespace,
+		Name:      "bucket_quota_bytes",
+		Help:      "Quota of the bucket, 0 if the bucket has no quota.",
+	}, bucketLabels)
+)
+
+var userExpirationTimestamp = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+	Namespace: metricsNamespace,
+	Name:      "user_expiration_timestamp_seconds",
+	Help:      "Time at which the user expires, in seconds since the epoch. Subtract time() for the remaining lifetime.",
+}, userLabels)
+
+func init() {
+	metrics.Registry.MustRegister(
+		minioRequestsTotal,
+		minioRequestDuration,
+		driftCorrectionsTotal,
+		minioUp,
+		finalizerDuration,
+		bucketSizeBytes,
+		bucketObjects,
+		bucketVersions,
+		bucketQuotaBytes,
+		userExpirationTimestamp,
+	)
+}
+
+func setBucketUsageMetrics(cr *operatorv1.Bucket, usage madmin.BucketUsageInfo, quota uint64) {
+	labels := prometheus.Labels{"namespace": cr.Namespace, "name": cr.Name, "bucket": cr.Spec.Name}
+	bucketSizeBytes.With(labels).Set(float64(usage.Size))
+	bucketObjects.With(labels).Set(float64(usage.ObjectsCount))
+	bucketVersions.With(labels).Set(float64(usage.VersionsCount))
+	bucketQuotaBytes.With(labels).Set(float64(quota))
+}
+
+func deleteBucketUsageMetrics(cr *operatorv1.Bucket) {
+	labels := prometheus.Labels{"namespace": cr.Namespace, "name": cr.Name, "bucket": cr.Spec.Name}
+	bucketSizeBytes.Delete(labels)
+	bucketObjects.Delete(labels)
+	bucketVersions.Delete(labels)
+	bucketQuotaBytes.Delete(labels)
+}
+
+func setUserExpirationMetric(cr *operatorv1.User) {
+	labels := prometheus.Labels{"namespace": cr.Namespace, "name": cr.Name, "access_key": cr.Spec.AccessKey}
+	if cr.Spec.ExpiresAt == nil {
+		userExpirationTimestamp.Delete(labels)
+		return
+	}
+	userExpirationTimestamp.With(labels).Set(float64(cr.Spec.ExpiresAt.Unix()))
+}
+
+func deleteUserExpirationMetric(cr *operatorv1.User) {
+	userExpirationTimestamp.Delete(prometheus.Labels{"namespace": cr.Namespace, "name": cr.Name, "access_key": cr.Spec.AccessKey})
+}
+
+// Call a MinIO API, recording its outcome and latency
+func trackCall(operation string, call func() error) error {
+	start := time.Now()
+	err := call()
+	observeCall(operation, start, err)
+	return err
+}
+
+// Call a MinIO API returning a value, recording its outcome and latency
+func trackCallResult[T any](operation string, call func() (T, error)) (T, error) {
+	start := time.Now()
+	result, err := call()
+	observeCall(operation, start, err)
+	return result, err
+}
+
+func observeCall(operation string, start time.Time, err error) {
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeError
+	}
+	minioRequestsTotal.WithLabelValues(operation, outcome).Inc()
+	minioRequestDuration.WithLabelValues(operation, outcome).Observe(time.Since(start).Seconds())
+}
+
+// Time finalizer operations for the given kind
+func trackFinalizer(kind string, finalizer func() error) error {
+	start := time.Now()
+	err := finalizer()
+	if isBucketDeletionPending(err) {
+		// Only the call completing the finalizer is observed
+		return err
+	}
+	outcome := outcomeSuccess
+	if err != nil {
+		outcome = outcomeError
+	}
+	finalizerDuration.WithLabelValues(kind, outcome).Observe(time.Since(start).Seconds())
+	return err
+}
+
+func setMinioUp(up bool) {
+	if up {
+		minioUp.Set(1)
+	} else {
+		minioUp.Set(0)
+	}
+}
+
+func recordDriftCorrection(kind string) {
+	driftCorrectionsTotal.WithLabelValues(kind).Inc()
+}
+
+var resourcesDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(metricsNamespace, "", "resources"),
+	"Number of custom resources, by kind and state.",
+	[]string{"kind", "state"}, nil,
+)
+
+// resourceStateCollector counts custom resources by state when metrics are scraped
+type resourceStateCollector struct {
+	reader client.Reader
+}
+
+// RegisterResourceStateMetrics exposes the number of custom resources in each state, read through the given reader
+func RegisterResourceStateMetrics(reader client.Reader) error {
+	return metrics.Registry.Register(&resourceStateCollector{reader: reader})
+}
+
+func (c *resourceStateCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- resourcesDesc
+}
+
+func (c *resourceStateCollector) Collect(ch chan<- prometheus.Metric) {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	c.collectStates(ctx, ch, "Bucket", &operatorv1.BucketList{}, func(obj runtime.Object) string {
+		return obj.(*operatorv1.Bucket).Status.State
+	})
+	c.collectStates(ctx, ch, "User", &operatorv1.UserList{}, func(obj runtime.Object) string {
+		return obj.(*operatorv1.User).Status.State
+	})
+	c.collectStates(ctx, ch, "Policy", &operatorv1.PolicyList{}, func(obj runtime.Object) string {
+		return obj.(*operatorv1.Policy).Status.State
+	})
+	c.collectStates(ctx, ch, "ClusterPolicy", &operatorv1.ClusterPolicyList{}, func(obj runtime.Object) string {
+		return obj.(*operatorv1.ClusterPolicy).Status.State
+	})
+	c.collectStates(ctx, ch, "BucketAccess", &operatorv1.BucketAccessList{}, func(obj runtime.Object) string {
+		return obj.(*operatorv1.BucketAccess).Status.State
+	})
+}
+
+// Count the resources of a kind by the state read from each item of the list, skipping the kind
+// if it cannot be listed
+func (c *resourceStateCollector) collectStates(ctx context.Context, ch chan<- prometheus.Metric, kind string, list client.ObjectList, state func(runtime.Object) string) {
+	if err := c.reader.List(ctx, list); err != nil {
+		return
+	}
+	items, err := meta.ExtractList(list)
+	if err != nil {
+		return
+	}
+
+	counts := map[string]int{}
+	for _, item := range items {
+		counts[state(item)]++
+	}
+	for state, n := range counts {
+		ch <- prometheus.MustNewConstMetric(resourcesDesc, prometheus.GaugeValue, float64(n), kind, state)
+	}
+}
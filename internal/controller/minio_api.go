@@ -0,0 +1,501 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/minio/madmin-go/v3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// Returned by VerifyCredentials when MinIO rejects the credentials
+var errInvalidCredentials = errors.New("invalid credentials")
+
+// ObjectStore is the subset of the S3 API used to archive buckets, which may be on another MinIO instance
+type ObjectStore interface {
+	MakeBucket(ctx context.Context, bucket string) error
+	// ListObjects lists the objects of a bucket, ending with an error if the listing is cut short.
+	// The channel must be drained, also after cancelling ctx.
+	ListObjects(ctx context.Context, bucket string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo
+	// GetObject returns the content of an object, which must be closed, and its info
+	GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, minio.ObjectInfo, error)
+	PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) error
+	StatObject(ctx context.Context, bucket string, key string) (minio.ObjectInfo, error)
+	GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error)
+	SetBucketLifecycle(ctx context.Context, bucket string, config *lifecycle.Configuration) error
+}
+
+// MinioAPI is the subset of the MinIO S3 and admin APIs used by the reconcilers
+type MinioAPI interface {
+	ObjectStore
+
+	RemoveBucket(ctx context.Context, bucket string) error
+	// RemoveObjects deletes the objects read from the channel, returning an error for each object not deleted
+	RemoveObjects(ctx context.Context, bucket string, objects <-chan minio.ObjectInfo) []error
+	GetBucketQuota(ctx context.Context, bucket string) (madmin.BucketQuota, error)
+	SetBucketQuota(ctx context.Context, bucket string, quota *madmin.BucketQuota) error
+	DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error)
+
+	SetUser(ctx context.Context, accessKey string, secretKey string, status madmin.AccountStatus) error
+	SetUserStatus(ctx context.Context, accessKey string, status madmin.AccountStatus) error
+	GetUserInfo(ctx context.Context, accessKey string) (madmin.UserInfo, error)
+	GetGroupDescription(ctx context.Context, group string) (*madmin.GroupDesc, error)
+	RemoveUser(ctx context.Context, accessKey string) error
+	// VerifyCredentials signs a request with the given credentials, returning errInvalidCredentials if MinIO rejects them
+	VerifyCredentials(ctx context.Context, accessKey string, secretKey string) error
+	AttachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error
+	DetachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error
+
+	AddCannedPolicy(ctx context.Context, name string, policy []byte) error
+	InfoCannedPolicyV2(ctx context.Context, name string) (*madmin.PolicyInfo, error)
+	RemoveCannedPolicy(ctx context.Context, name string) error
+
+	ServerInfo(ctx context.Context) error
+}
+
+// minioAPI implements MinioAPI on the shared MinIO clients, recording metrics for every call
+type minioAPI struct {
+	// S3 client used instead of the shared one, when set
+	client *minio.Client
+}
+
+// NewMinioAPI returns a MinioAPI talking to the MinIO server configured through the environment
+func NewMinioAPI() MinioAPI {
+	return &minioAPI{}
+}
+
+// newObjectStore returns an ObjectStore on the given client
+func newObjectStore(client *minio.Client) ObjectStore {
+	return &minioAPI{client: client}
+}
+
+func (m *minioAPI) getClient() (*minio.Client, error) {
+	if m.client != nil {
+		return m.client, nil
+	}
+	return getClient()
+}
+
+// Call MinIO, recording the call. Credentials of the shared clients rejected by MinIO may have been
+// rotated, so they are reloaded on next use.
+func callMinio(m *minioAPI, operation string, call func() error) error {
+	err := trackCall(operation, call)
+	m.resetOnAuthError(err)
+	return err
+}
+
+func callMinioResult[T any](m *minioAPI, operation string, call func() (T, error)) (T, error) {
+	result, err := trackCallResult(operation, call)
+	m.resetOnAuthError(err)
+	return result, err
+}
+
+func (m *minioAPI) resetOnAuthError(err error) {
+	if m.client == nil && isAuthError(err) {
+		resetClients()
+	}
+}
+
+func (m *minioAPI) MakeBucket(ctx context.Context, bucket string) error {
+	client, err := m.getClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "MakeBucket", func() error {
+		return client.MakeBucket(ctx, bucket, minio.MakeBucketOptions{})
+	})
+}
+
+func (m *minioAPI) RemoveBucket(ctx context.Context, bucket string) error {
+	client, err := m.getClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "RemoveBucket", func() error {
+		return client.RemoveBucket(ctx, bucket)
+	})
+}
+
+func (m *minioAPI) ListObjects(ctx context.Context, bucket string, opts minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	client, err := m.getClient()
+	if err != nil {
+		objectsCh := make(chan minio.ObjectInfo, 1)
+		objectsCh <- minio.ObjectInfo{Err: err}
+		close(objectsCh)
+		return objectsCh
+	}
+
+	// Listings are not bound by the operation timeout, as they take as long as the caller takes
+	// to consume them. A listing cut short by ctx ends with its error.
+	objectsCh := make(chan minio.ObjectInfo)
+	go func() {
+		defer close(objectsCh)
+		// Recorded when the listing ends, as failed if any object could not be listed
+		_ = callMinio(m, "ListObjects", func() error {
+			var listErr error
+			for object := range client.ListObjects(ctx, bucket, opts) {
+				if object.Err != nil {
+					listErr = object.Err
+				}
+				select {
+				case objectsCh <- object:
+				case <-ctx.Done():
+				}
+				if ctx.Err() != nil {
+					break
+				}
+			}
+			if ctx.Err() != nil {
+				listErr = ctx.Err()
+				objectsCh <- minio.ObjectInfo{Err: listErr}
+			}
+			return listErr
+		})
+	}()
+
+	return objectsCh
+}
+
+func (m *minioAPI) RemoveObjects(ctx context.Context, bucket string, objects <-chan minio.ObjectInfo) []error {
+	client, err := m.getClient()
+	if err != nil {
+		return []error{err}
+	}
+
+	// Like listings, removals take as long as the objects take to be read from the channel
+	var errs []error
+	err = callMinio(m, "RemoveObjects", func() error {
+		for result := range client.RemoveObjects(ctx, bucket, objects, minio.RemoveObjectsOptions{GovernanceBypass: true}) {
+			errs = append(errs, result.Err)
+		}
+		if ctx.Err() != nil {
+			// Objects not yet removed are not reported
+			errs = append(errs, ctx.Err())
+		}
+		if len(errs) > 0 {
+			return errs[len(errs)-1]
+		}
+		return nil
+	})
+	if err != nil && (len(errs) == 0 || errs[len(errs)-1] != err) {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+func (m *minioAPI) GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, minio.ObjectInfo, error) {
+	client, err := m.getClient()
+	if err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	// Transfers are not bound by the operation timeout, as they take as long as the object is large
+	var object *minio.Object
+	info, err := callMinioResult(m, "GetObject", func() (minio.ObjectInfo, error) {
+		object, err = client.GetObject(ctx, bucket, key, minio.GetObjectOptions{})
+		if err != nil {
+			return minio.ObjectInfo{}, err
+		}
+		return object.Stat()
+	})
+	if err != nil {
+		if object != nil {
+			object.Close()
+		}
+		return nil, minio.ObjectInfo{}, err
+	}
+
+	return object, info, nil
+}
+
+func (m *minioAPI) PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) error {
+	client, err := m.getClient()
+	if err != nil {
+		return err
+	}
+
+	return callMinio(m, "PutObject", func() error {
+		_, err := client.PutObject(ctx, bucket, key, reader, size, opts)
+		return err
+	})
+}
+
+func (m *minioAPI) StatObject(ctx context.Context, bucket string, key string) (minio.ObjectInfo, error) {
+	client, err := m.getClient()
+	if err != nil {
+		return minio.ObjectInfo{}, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "StatObject", func() (minio.ObjectInfo, error) {
+		return client.StatObject(ctx, bucket, key, minio.StatObjectOptions{})
+	})
+}
+
+func (m *minioAPI) GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error) {
+	client, err := m.getClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "GetBucketLifecycle", func() (*lifecycle.Configuration, error) {
+		return client.GetBucketLifecycle(ctx, bucket)
+	})
+}
+
+func (m *minioAPI) SetBucketLifecycle(ctx context.Context, bucket string, config *lifecycle.Configuration) error {
+	client, err := m.getClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "SetBucketLifecycle", func() error {
+		return client.SetBucketLifecycle(ctx, bucket, config)
+	})
+}
+
+func (m *minioAPI) GetBucketQuota(ctx context.Context, bucket string) (madmin.BucketQuota, error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return madmin.BucketQuota{}, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "GetBucketQuota", func() (madmin.BucketQuota, error) {
+		return adminClient.GetBucketQuota(ctx, bucket)
+	})
+}
+
+func (m *minioAPI) SetBucketQuota(ctx context.Context, bucket string, quota *madmin.BucketQuota) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "SetBucketQuota", func() error {
+		return adminClient.SetBucketQuota(ctx, bucket, quota)
+	})
+}
+
+func (m *minioAPI) DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return madmin.DataUsageInfo{}, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "DataUsageInfo", func() (madmin.DataUsageInfo, error) {
+		return adminClient.DataUsageInfo(ctx)
+	})
+}
+
+func (m *minioAPI) SetUser(ctx context.Context, accessKey string, secretKey string, status madmin.AccountStatus) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "SetUser", func() error {
+		return adminClient.SetUser(ctx, accessKey, secretKey, status)
+	})
+}
+
+func (m *minioAPI) SetUserStatus(ctx context.Context, accessKey string, status madmin.AccountStatus) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "SetUserStatus", func() error {
+		return adminClient.SetUserStatus(ctx, accessKey, status)
+	})
+}
+
+func (m *minioAPI) GetUserInfo(ctx context.Context, accessKey string) (madmin.UserInfo, error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return madmin.UserInfo{}, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "GetUserInfo", func() (madmin.UserInfo, error) {
+		return adminClient.GetUserInfo(ctx, accessKey)
+	})
+}
+
+func (m *minioAPI) GetGroupDescription(ctx context.Context, group string) (*madmin.GroupDesc, error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "GetGroupDescription", func() (*madmin.GroupDesc, error) {
+		return adminClient.GetGroupDescription(ctx, group)
+	})
+}
+
+func (m *minioAPI) RemoveUser(ctx context.Context, accessKey string) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "RemoveUser", func() error {
+		return adminClient.RemoveUser(ctx, accessKey)
+	})
+}
+
+func (m *minioAPI) VerifyCredentials(ctx context.Context, accessKey string, secretKey string) error {
+	client, err := getClientWithCredentials(accessKey, secretKey)
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return trackCall("VerifyCredentials", func() error {
+		_, err := client.ListBuckets(ctx)
+		if isAuthError(err) {
+			// Not reported as an auth error, which is about the operator's own credentials
+			return fmt.Errorf("%w: %s", errInvalidCredentials, errorCode(err))
+		}
+		if hasErrorCode(err, codeAccessDenied) {
+			// The signature was accepted, the user is just not allowed to list buckets
+			return nil
+		}
+		return err
+	})
+}
+
+func (m *minioAPI) AttachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "AttachPolicy", func() error {
+		_, err := adminClient.AttachPolicy(ctx, req)
+		return err
+	})
+}
+
+func (m *minioAPI) DetachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "DetachPolicy", func() error {
+		_, err := adminClient.DetachPolicy(ctx, req)
+		return err
+	})
+}
+
+func (m *minioAPI) AddCannedPolicy(ctx context.Context, name string, policy []byte) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "AddCannedPolicy", func() error {
+		return adminClient.AddCannedPolicy(ctx, name, policy)
+	})
+}
+
+func (m *minioAPI) InfoCannedPolicyV2(ctx context.Context, name string) (*madmin.PolicyInfo, error) {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinioResult(m, "InfoCannedPolicyV2", func() (*madmin.PolicyInfo, error) {
+		return adminClient.InfoCannedPolicyV2(ctx, name)
+	})
+}
+
+func (m *minioAPI) RemoveCannedPolicy(ctx context.Context, name string) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "RemoveCannedPolicy", func() error {
+		return adminClient.RemoveCannedPolicy(ctx, name)
+	})
+}
+
+func (m *minioAPI) ServerInfo(ctx context.Context) error {
+	adminClient, err := getAdminClient()
+	if err != nil {
+		return err
+	}
+
+	ctx, cancel := operationContext(ctx)
+	defer cancel()
+
+	return callMinio(m, "ServerInfo", func() error {
+		_, err := adminClient.ServerInfo(ctx)
+		return err
+	})
+}
@@ -0,0 +1,119 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/credentials"
+)
+
+// S3 server holding objects object-0, object-1... of one byte each in test-bucket
+type stubS3 struct {
+	objects int
+	// Objects per page of a listing, all in one page when 0
+	pageSize int
+	// Fail the listing of any page after the first
+	failNextPages bool
+	// Time taken to serve an object
+	objectDelay time.Duration
+}
+
+func (s stubS3) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/xml")
+	if r.URL.Query().Get("list-type") == "" {
+		time.Sleep(s.objectDelay)
+		w.Header().Set("Content-Type", "text/plain")
+		w.Header().Set("Content-Length", "1")
+		w.Header().Set("ETag", `"etag"`)
+		w.Header().Set("Last-Modified", "Wed, 01 Jan 2025 00:00:00 GMT")
+		fmt.Fprint(w, "x")
+		return
+	}
+
+	start, _ := strconv.Atoi(r.URL.Query().Get("continuation-token"))
+	if start > 0 && s.failNextPages {
+		w.WriteHeader(http.StatusForbidden)
+		fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><Error><Code>AccessDenied</Code><Message>Access Denied.</Message></Error>`)
+		return
+	}
+	end := s.objects
+	if s.pageSize > 0 {
+		end = min(start+s.pageSize, s.objects)
+	}
+
+	fmt.Fprint(w, `<?xml version="1.0" encoding="UTF-8"?><ListBucketResult xmlns="http://s3.amazonaws.com/doc/2006-03-01/">`)
+	fmt.Fprintf(w, "<Name>test-bucket</Name><KeyCount>%d</KeyCount><MaxKeys>1000</MaxKeys>", end-start)
+	if end < s.objects {
+		fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%d</NextContinuationToken>", end)
+	} else {
+		fmt.Fprint(w, "<IsTruncated>false</IsTruncated>")
+	}
+	for i := start; i < end; i++ {
+		fmt.Fprintf(w, `<Contents><Key>object-%d</Key><Size>1</Size><ETag>"etag"</ETag><LastModified>2025-01-01T00:00:00.000Z</LastModified></Contents>`, i)
+	}
+	fmt.Fprint(w, "</ListBucketResult>")
+}
+
+// Build a minioAPI on the given S3 server
+func newStubMinioAPI(t *testing.T, stub stubS3) *minioAPI {
+	server := httptest.NewServer(stub)
+	t.Cleanup(server.Close)
+
+	client, err := minio.New(strings.TrimPrefix(server.URL, "http://"), &minio.Options{
+		Creds:  credentials.NewStaticV4("access-key", "secret-key", ""),
+		Region: "us-east-1",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &minioAPI{client: client}
+}
+
+func TestMinioAPIListObjectsSlowConsumer(t *testing.T) {
+	previous := operationTimeout
+	operationTimeout = 200 * time.Millisecond
+	t.Cleanup(func() { operationTimeout = previous })
+	m := newStubMinioAPI(t, stubS3{objects: 10})
+
+	// Listings take longer than the operation timeout when consumed slowly
+	listed := 0
+	for object := range m.ListObjects(context.Background(), "test-bucket", minio.ListObjectsOptions{Recursive: true}) {
+		if object.Err != nil {
+			t.Fatalf("unexpected error after %d objects: %v", listed, object.Err)
+		}
+		listed++
+		time.Sleep(50 * time.Millisecond)
+	}
+	if listed != 10 {
+		t.Fatalf("expected 10 objects, got %d", listed)
+	}
+}
+
+func TestMinioAPIListObjectsCancelled(t *testing.T) {
+	m := newStubMinioAPI(t, stubS3{objects: 10})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	var last minio.ObjectInfo
+	for object := range m.ListObjects(ctx, "test-bucket", minio.ListObjectsOptions{Recursive: true}) {
+		cancel()
+		last = object
+	}
+	if !errors.Is(last.Err, context.Canceled) {
+		t.Fatalf("expected listing to end with the cancellation, got %+v", last)
+	}
+}
@@ -0,0 +1,569 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+	"github.com/minio/minio-go/v7"
+	"github.com/minio/minio-go/v7/pkg/lifecycle"
+)
+
+// fakeMinio is an in-memory MinioAPI, returning the same error codes and messages as a real server
+type fakeMinio struct {
+	mu sync.Mutex
+
+	// Bucket name to object key and object
+	buckets    map[string]map[string]*fakeObject
+	quotas     map[string]uint64
+	lifecycles map[string]*lifecycle.Configuration
+	users      map[string]*fakeUser
+	// Group name to attached policies
+	groups map[string][]string
+	// Policy name to compacted content
+	policies map[string][]byte
+
+	// Errors returned by the next calls to an operation, by operation name
+	failures map[string]error
+	// Number of calls per operation
+	calls map[string]int
+}
+
+type fakeObject struct {
+	data        []byte
+	contentType string
+	metadata    map[string]string
+}
+
+func (o *fakeObject) info(key string) minio.ObjectInfo {
+	return minio.ObjectInfo{
+		Key:          key,
+		Size:         int64(len(o.data)),
+		ETag:         fmt.Sprintf("%x", md5.Sum(o.data)),
+		ContentType:  o.contentType,
+		UserMetadata: o.metadata,
+	}
+}
+
+type fakeUser struct {
+	secretKey string
+	status    madmin.AccountStatus
+	policies  []string
+	groups    []string
+}
+
+func newFakeMinio() *fakeMinio {
+	return &fakeMinio{
+		buckets:    map[string]map[string]*fakeObject{},
+		quotas:     map[string]uint64{},
+		lifecycles: map[string]*lifecycle.Configuration{},
+		users:      map[string]*fakeUser{},
+		groups:     map[string][]string{},
+		policies:   map[string][]byte{},
+		failures:   map[string]error{},
+		calls:      map[string]int{},
+	}
+}
+
+// Make every following call to operation fail with err, until failing again with a nil error
+func (f *fakeMinio) fail(operation string, err error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err == nil {
+		delete(f.failures, operation)
+		return
+	}
+	f.failures[operation] = err
+}
+
+func (f *fakeMinio) putObject(bucket string, key string, size uint64) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	f.buckets[bucket][key] = &fakeObject{data: make([]byte, size)}
+}
+
+// Record a call, returning the error injected for it, if any. Must be called with the lock held.
+func (f *fakeMinio) call(ctx context.Context, operation string) error {
+	f.calls[operation]++
+	if err := ctx.Err(); err != nil {
+		return err
+	}
+	return f.failures[operation]
+}
+
+func s3Error(statusCode int, code string, message string) error {
+	return minio.ErrorResponse{StatusCode: statusCode, Code: code, Message: message}
+}
+
+func adminError(code string, message string) error {
+	return madmin.ErrorResponse{Code: code, Message: message}
+}
+
+func errNoSuchBucket() error {
+	return s3Error(http.StatusNotFound, "NoSuchBucket", "The specified bucket does not exist")
+}
+
+func errNoSuchKey() error {
+	return s3Error(http.StatusNotFound, "NoSuchKey", "The specified key does not exist.")
+}
+
+func errNoSuchUser() error {
+	return adminError("XMinioAdminNoSuchUser", "The specified user does not exist.")
+}
+
+func errNoSuchPolicy() error {
+	return adminError("XMinioAdminNoSuchPolicy", "The canned policy does not exist.")
+}
+
+func (f *fakeMinio) MakeBucket(ctx context.Context, bucket string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "MakeBucket"); err != nil {
+		return err
+	}
+	if _, found := f.buckets[bucket]; found {
+		return s3Error(http.StatusConflict, "BucketAlreadyOwnedByYou",
+			"Your previous request to create the named bucket succeeded and you already own it.")
+	}
+
+	f.buckets[bucket] = map[string]*fakeObject{}
+	return nil
+}
+
+func (f *fakeMinio) RemoveBucket(ctx context.Context, bucket string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "RemoveBucket"); err != nil {
+		return err
+	}
+	objects, found := f.buckets[bucket]
+	if !found {
+		return errNoSuchBucket()
+	}
+	if len(objects) > 0 {
+		return s3Error(http.StatusConflict, "BucketNotEmpty", "The bucket you tried to delete is not empty")
+	}
+
+	delete(f.buckets, bucket)
+	delete(f.quotas, bucket)
+	return nil
+}
+
+func (f *fakeMinio) ListObjects(ctx context.Context, bucket string, _ minio.ListObjectsOptions) <-chan minio.ObjectInfo {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "ListObjects"); err != nil {
+		objectsCh := make(chan minio.ObjectInfo, 1)
+		objectsCh <- minio.ObjectInfo{Err: err}
+		close(objectsCh)
+		return objectsCh
+	}
+
+	objectsCh := make(chan minio.ObjectInfo, len(f.buckets[bucket]))
+	for key, object := range f.buckets[bucket] {
+		objectsCh <- object.info(key)
+	}
+	close(objectsCh)
+	return objectsCh
+}
+
+func (f *fakeMinio) RemoveObjects(ctx context.Context, bucket string, objects <-chan minio.ObjectInfo) []error {
+	var keys []string
+	for object := range objects {
+		keys = append(keys, object.Key)
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "RemoveObjects"); err != nil {
+		errs := make([]error, len(keys))
+		for i := range errs {
+			errs[i] = err
+		}
+		return errs
+	}
+	if _, found := f.buckets[bucket]; !found {
+		return []error{errNoSuchBucket()}
+	}
+	for _, key := range keys {
+		delete(f.buckets[bucket], key)
+	}
+	return nil
+}
+
+func (f *fakeMinio) GetObject(ctx context.Context, bucket string, key string) (io.ReadCloser, minio.ObjectInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "GetObject"); err != nil {
+		return nil, minio.ObjectInfo{}, err
+	}
+	objects, found := f.buckets[bucket]
+	if !found {
+		return nil, minio.ObjectInfo{}, errNoSuchBucket()
+	}
+	object, found := objects[key]
+	if !found {
+		return nil, minio.ObjectInfo{}, errNoSuchKey()
+	}
+
+	return io.NopCloser(bytes.NewReader(object.data)), object.info(key), nil
+}
+
+func (f *fakeMinio) PutObject(ctx context.Context, bucket string, key string, reader io.Reader, size int64, opts minio.PutObjectOptions) error {
+	data, err := io.ReadAll(reader)
+	if err != nil {
+		return err
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "PutObject"); err != nil {
+		return err
+	}
+	objects, found := f.buckets[bucket]
+	if !found {
+		return errNoSuchBucket()
+	}
+	if int64(len(data)) != size {
+		return s3Error(http.StatusBadRequest, "IncompleteBody", "You did not provide the number of bytes specified by the Content-Length HTTP header.")
+	}
+
+	objects[key] = &fakeObject{data: data, contentType: opts.ContentType, metadata: opts.UserMetadata}
+	return nil
+}
+
+func (f *fakeMinio) StatObject(ctx context.Context, bucket string, key string) (minio.ObjectInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "StatObject"); err != nil {
+		return minio.ObjectInfo{}, err
+	}
+	objects, found := f.buckets[bucket]
+	if !found {
+		return minio.ObjectInfo{}, errNoSuchBucket()
+	}
+	object, found := objects[key]
+	if !found {
+		return minio.ObjectInfo{}, errNoSuchKey()
+	}
+
+	return object.info(key), nil
+}
+
+func (f *fakeMinio) GetBucketLifecycle(ctx context.Context, bucket string) (*lifecycle.Configuration, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "GetBucketLifecycle"); err != nil {
+		return nil, err
+	}
+	if _, found := f.buckets[bucket]; !found {
+		return nil, errNoSuchBucket()
+	}
+	config, found := f.lifecycles[bucket]
+	if !found {
+		return nil, s3Error(http.StatusNotFound, "NoSuchLifecycleConfiguration", "The lifecycle configuration does not exist")
+	}
+
+	return config, nil
+}
+
+func (f *fakeMinio) SetBucketLifecycle(ctx context.Context, bucket string, config *lifecycle.Configuration) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "SetBucketLifecycle"); err != nil {
+		return err
+	}
+	if _, found := f.buckets[bucket]; !found {
+		return errNoSuchBucket()
+	}
+
+	f.lifecycles[bucket] = config
+	return nil
+}
+
+func (f *fakeMinio) GetBucketQuota(ctx context.Context, bucket string) (madmin.BucketQuota, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "GetBucketQuota"); err != nil {
+		return madmin.BucketQuota{}, err
+	}
+	if _, found := f.buckets[bucket]; !found {
+		return madmin.BucketQuota{}, adminError("NoSuchBucket", "The specified bucket does not exist")
+	}
+	if f.quotas[bucket] == 0 {
+		return madmin.BucketQuota{}, nil
+	}
+
+	return madmin.BucketQuota{Quota: f.quotas[bucket], Size: f.quotas[bucket], Type: madmin.HardQuota}, nil
+}
+
+func (f *fakeMinio) SetBucketQuota(ctx context.Context, bucket string, quota *madmin.BucketQuota) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "SetBucketQuota"); err != nil {
+		return err
+	}
+	if _, found := f.buckets[bucket]; !found {
+		return adminError("NoSuchBucket", "The specified bucket does not exist")
+	}
+
+	f.quotas[bucket] = currentQuota(*quota)
+	return nil
+}
+
+func (f *fakeMinio) DataUsageInfo(ctx context.Context) (madmin.DataUsageInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "DataUsageInfo"); err != nil {
+		return madmin.DataUsageInfo{}, err
+	}
+
+	dataUsage := madmin.DataUsageInfo{
+		LastUpdate:   time.Date(2025, time.January, 1, 0, 0, 0, 0, time.UTC),
+		BucketsUsage: map[string]madmin.BucketUsageInfo{},
+	}
+	for bucket, objects := range f.buckets {
+		usage := madmin.BucketUsageInfo{ObjectsCount: uint64(len(objects)), VersionsCount: uint64(len(objects))}
+		for _, object := range objects {
+			usage.Size += uint64(len(object.data))
+		}
+		dataUsage.BucketsUsage[bucket] = usage
+	}
+	return dataUsage, nil
+}
+
+func (f *fakeMinio) SetUser(ctx context.Context, accessKey string, secretKey string, status madmin.AccountStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "SetUser"); err != nil {
+		return err
+	}
+
+	user, found := f.users[accessKey]
+	if !found {
+		user = &fakeUser{}
+		f.users[accessKey] = user
+	}
+	user.secretKey = secretKey
+	user.status = status
+	return nil
+}
+
+func (f *fakeMinio) SetUserStatus(ctx context.Context, accessKey string, status madmin.AccountStatus) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "SetUserStatus"); err != nil {
+		return err
+	}
+	user, found := f.users[accessKey]
+	if !found {
+		return errNoSuchUser()
+	}
+	user.status = status
+	return nil
+}
+
+func (f *fakeMinio) GetUserInfo(ctx context.Context, accessKey string) (madmin.UserInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "GetUserInfo"); err != nil {
+		return madmin.UserInfo{}, err
+	}
+	user, found := f.users[accessKey]
+	if !found {
+		return madmin.UserInfo{}, errNoSuchUser()
+	}
+
+	return madmin.UserInfo{
+		Status:     user.status,
+		PolicyName: strings.Join(user.policies, ","),
+		MemberOf:   user.groups,
+	}, nil
+}
+
+func (f *fakeMinio) GetGroupDescription(ctx context.Context, group string) (*madmin.GroupDesc, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "GetGroupDescription"); err != nil {
+		return nil, err
+	}
+	policies, found := f.groups[group]
+	if !found {
+		return nil, adminError("XMinioAdminNoSuchGroup", "The specified group does not exist.")
+	}
+
+	return &madmin.GroupDesc{Name: group, Policy: strings.Join(policies, ",")}, nil
+}
+
+func (f *fakeMinio) RemoveUser(ctx context.Context, accessKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "RemoveUser"); err != nil {
+		return err
+	}
+	if _, found := f.users[accessKey]; !found {
+		return errNoSuchUser()
+	}
+
+	delete(f.users, accessKey)
+	return nil
+}
+
+func (f *fakeMinio) VerifyCredentials(ctx context.Context, accessKey string, secretKey string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "VerifyCredentials"); err != nil {
+		return err
+	}
+	user, found := f.users[accessKey]
+	if !found || user.status != madmin.AccountEnabled || user.secretKey != secretKey {
+		return fmt.Errorf("%w: %s", errInvalidCredentials, "SignatureDoesNotMatch")
+	}
+
+	return nil
+}
+
+func (f *fakeMinio) AttachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "AttachPolicy"); err != nil {
+		return err
+	}
+	user, found := f.users[req.User]
+	if !found {
+		return errNoSuchUser()
+	}
+
+	attached := false
+	for _, policy := range req.Policies {
+		if _, found := f.policies[policy]; !found {
+			return errNoSuchPolicy()
+		}
+		if !slices.Contains(user.policies, policy) {
+			user.policies = append(user.policies, policy)
+			attached = true
+		}
+	}
+	if !attached {
+		return adminError("XMinioAdminPolicyChangeAlreadyApplied", "Specified policy update has no net effect")
+	}
+
+	sort.Strings(user.policies)
+	return nil
+}
+
+func (f *fakeMinio) DetachPolicy(ctx context.Context, req madmin.PolicyAssociationReq) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "DetachPolicy"); err != nil {
+		return err
+	}
+	user, found := f.users[req.User]
+	if !found {
+		return errNoSuchUser()
+	}
+
+	var remaining []string
+	for _, policy := range user.policies {
+		if !slices.Contains(req.Policies, policy) {
+			remaining = append(remaining, policy)
+		}
+	}
+	if len(remaining) == len(user.policies) {
+		return adminError("XMinioAdminPolicyChangeAlreadyApplied", "Specified policy update has no net effect")
+	}
+
+	user.policies = remaining
+	return nil
+}
+
+func (f *fakeMinio) AddCannedPolicy(ctx context.Context, name string, policy []byte) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "AddCannedPolicy"); err != nil {
+		return err
+	}
+
+	// MinIO stores policies in its own serialization
+	compacted := new(bytes.Buffer)
+	if err := json.Compact(compacted, policy); err != nil {
+		return adminError("XMinioMalformedIAMPolicy", "policy has invalid resource")
+	}
+
+	f.policies[name] = compacted.Bytes()
+	return nil
+}
+
+func (f *fakeMinio) InfoCannedPolicyV2(ctx context.Context, name string) (*madmin.PolicyInfo, error) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "InfoCannedPolicyV2"); err != nil {
+		return nil, err
+	}
+	policy, found := f.policies[name]
+	if !found {
+		return nil, errNoSuchPolicy()
+	}
+
+	return &madmin.PolicyInfo{PolicyName: name, Policy: json.RawMessage(policy)}, nil
+}
+
+func (f *fakeMinio) RemoveCannedPolicy(ctx context.Context, name string) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	if err := f.call(ctx, "RemoveCannedPolicy"); err != nil {
+		return err
+	}
+	if _, found := f.policies[name]; !found {
+		return errNoSuchPolicy()
+	}
+
+	delete(f.policies, name)
+	return nil
+}
+
+func (f *fakeMinio) ServerInfo(ctx context.Context) error {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	return f.call(ctx, "ServerInfo")
+}
@@ -0,0 +1,129 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Field index of the MinIO name of Buckets, Policies and ClusterPolicies, to find the resources
+// claiming a name
+const minioNameIndex = "spec.name"
+
+func indexBucketName(obj client.Object) []string {
+	return []string{obj.(*operatorv1.Bucket).Spec.Name}
+}
+
+func indexPolicyName(obj client.Object) []string {
+	return []string{obj.(*operatorv1.Policy).Spec.Name}
+}
+
+func indexClusterPolicyName(obj client.Object) []string {
+	return []string{obj.(*operatorv1.ClusterPolicy).Spec.Name}
+}
+
+// Buckets or Policies in any namespace, or ClusterPolicies, with the same MinIO name as obj, and
+// their finalizer
+func nameClaims(ctx context.Context, c client.Reader, obj client.Object) ([]client.Object, string, error) {
+	var claims []client.Object
+	switch cr := obj.(type) {
+	case *operatorv1.Bucket:
+		buckets := &operatorv1.BucketList{}
+		if err := c.List(ctx, buckets, client.MatchingFields{minioNameIndex: cr.Spec.Name}); err != nil {
+			return nil, "", err
+		}
+		for i := range buckets.Items {
+			claims = append(claims, &buckets.Items[i])
+		}
+		return claims, bucketFinalizer, nil
+	case *operatorv1.Policy:
+		policies := &operatorv1.PolicyList{}
+		if err := c.List(ctx, policies, client.MatchingFields{minioNameIndex: cr.Spec.Name}); err != nil {
+			return nil, "", err
+		}
+		for i := range policies.Items {
+			claims = append(claims, &policies.Items[i])
+		}
+		return claims, policyFinalizer, nil
+	case *operatorv1.ClusterPolicy:
+		policies := &operatorv1.ClusterPolicyList{}
+		if err := c.List(ctx, policies, client.MatchingFields{minioNameIndex: cr.Spec.Name}); err != nil {
+			return nil, "", err
+		}
+		for i := range policies.Items {
+			claims = append(claims, &policies.Items[i])
+		}
+		return claims, clusterPolicyFinalizer, nil
+	}
+
+	return nil, "", fmt.Errorf("unsupported resource %T", obj)
+}
+
+// Return the namespace owning the MinIO name of a Bucket or Policy when it is not the one of obj,
+// or the ClusterPolicy owning the name when it is not obj, empty otherwise. Resources holding
+// their finalizer were written to MinIO and own the name before any other, then the oldest
+// resource does.
+func nameOwnedElsewhere(ctx context.Context, c client.Reader, obj client.Object) (string, error) {
+	claims, finalizer, err := nameClaims(ctx, c, obj)
+	if err != nil || len(claims) == 0 {
+		return "", err
+	}
+
+	owner := slices.MinFunc(claims, func(a, b client.Object) int {
+		aFinalized, bFinalized := controllerutil.ContainsFinalizer(a, finalizer), controllerutil.ContainsFinalizer(b, finalizer)
+		if aFinalized != bFinalized {
+			if aFinalized {
+				return -1
+			}
+			return 1
+		}
+
+		aCreated, bCreated := a.GetCreationTimestamp(), b.GetCreationTimestamp()
+		if !aCreated.Equal(&bCreated) {
+			if aCreated.Before(&bCreated) {
+				return -1
+			}
+			return 1
+		}
+
+		if a.GetNamespace() != b.GetNamespace() {
+			return strings.Compare(a.GetNamespace(), b.GetNamespace())
+		}
+		return strings.Compare(a.GetName(), b.GetName())
+	})
+	if obj.GetNamespace() == "" {
+		if owner.GetName() == obj.GetName() {
+			return "", nil
+		}
+		return owner.GetName(), nil
+	}
+	if owner.GetNamespace() == obj.GetNamespace() {
+		return "", nil
+	}
+
+	return owner.GetNamespace(), nil
+}
+
+// Check that the MinIO name of a Bucket or Policy is not owned by another namespace, or the one
+// of a ClusterPolicy by another ClusterPolicy
+func checkNameClaim(ctx context.Context, c client.Reader, obj client.Object) error {
+	owner, err := nameOwnedElsewhere(ctx, c, obj)
+	if err != nil || owner == "" {
+		return err
+	}
+
+	if obj.GetNamespace() == "" {
+		return fmt.Errorf("%w cluster policy %s", errNameClaimed, owner)
+	}
+	return fmt.Errorf("%w namespace %s", errNameClaimed, owner)
+}
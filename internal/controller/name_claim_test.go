@@ -0,0 +1,112 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestBucketReconcileNameClaimed(t *testing.T) {
+	t.Setenv(envEmptyBucketOnDelete, "true")
+
+	// Both were written to MinIO by an older release, the oldest owns the name
+	owner := newTestBucket("")
+	owner.Namespace = "team-a"
+	owner.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	owner.Finalizers = []string{bucketFinalizer}
+	cr := newTestBucket("")
+	cr.CreationTimestamp = metav1.Now()
+	cr.Finalizers = []string{bucketFinalizer}
+	cr.Status.State = typeReady
+	r, minio := newTestBucketReconciler(t, cr, owner)
+	minio.buckets["test-bucket"] = map[string]*fakeObject{}
+	minio.putObject("test-bucket", "a", 1)
+
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != conflictRequeueDelay {
+		t.Fatalf("expected requeue after %s, got %+v", conflictRequeueDelay, result)
+	}
+	refetch(t, r.Client, cr)
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if cr.Status.State != typeError || condition == nil || condition.Reason != string(errorClassConflict) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+
+	// The bucket of the owner is neither emptied nor removed
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if len(minio.buckets["test-bucket"]) != 1 {
+		t.Fatal("expected bucket to be kept")
+	}
+}
+
+func TestPolicyReconcileNameClaimed(t *testing.T) {
+	// The policy written to MinIO owns the name, even if the other is older
+	owner := newTestPolicy()
+	owner.Namespace = "team-a"
+	owner.CreationTimestamp = metav1.Now()
+	owner.Finalizers = []string{policyFinalizer}
+	cr := newTestPolicy()
+	cr.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	cr.Spec.Content = `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::*"}]}`
+	r, minio := newTestPolicyReconciler(t, cr, owner)
+	minio.policies["test-policy"] = []byte(testPolicyContent)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected policy owned by another namespace to be rejected, got %+v", cr.Status)
+	}
+	if string(minio.policies["test-policy"]) != testPolicyContent {
+		t.Fatal("expected policy of the owner not to be overwritten")
+	}
+}
+
+func TestClusterPolicyReconcileNameClaimed(t *testing.T) {
+	owner := newTestClusterPolicy()
+	owner.Name = "platform-policy"
+	owner.CreationTimestamp = metav1.NewTime(time.Now().Add(-time.Hour))
+	owner.Finalizers = []string{clusterPolicyFinalizer}
+	cr := newTestClusterPolicy()
+	cr.CreationTimestamp = metav1.Now()
+	cr.Spec.Content = `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::*"}]}`
+	r, minio := newTestClusterPolicyReconciler(t, cr, owner)
+	minio.policies["test-policy"] = []byte(testPolicyContent)
+
+	reconcileOnce(t, r, cr, false)
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter != conflictRequeueDelay {
+		t.Fatalf("expected requeue after %s, got %+v", conflictRequeueDelay, result)
+	}
+	refetch(t, r.Client, cr)
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if cr.Status.State != typeError || condition == nil || condition.Reason != string(errorClassConflict) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if string(minio.policies["test-policy"]) != testPolicyContent {
+		t.Fatal("expected policy of the owner not to be overwritten")
+	}
+
+	// The policy of the owner is not removed
+	cr.Finalizers = []string{clusterPolicyFinalizer}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.policies["test-policy"]; !found {
+		t.Fatal("expected policy to be kept")
+	}
+}
@@ -0,0 +1,169 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// Resources with this annotation set to true are not written to MinIO
+const pausedAnnotation = "minio.scc-digitalhub.github.io/paused"
+
+// When true, no resource is written to MinIO
+const envMaintenanceMode = "MINIO_MAINTENANCE_MODE"
+
+// ConfigMap switching maintenance mode on and off without restarting the operator
+const (
+	envMaintenanceConfigMap          = "MINIO_MAINTENANCE_CONFIGMAP"
+	envMaintenanceConfigMapNamespace = "MINIO_MAINTENANCE_CONFIGMAP_NAMESPACE"
+	defaultMaintenanceConfigMap      = "minio-operator-maintenance"
+	defaultMaintenanceNamespace      = "minio-operator-system"
+	maintenanceModeKey               = "maintenanceMode"
+)
+
+// How often paused resources are checked for drift
+const pausedDriftCheckInterval = 5 * time.Minute
+
+const (
+	conditionPaused  = "Paused"
+	conditionDrifted = "Drifted"
+)
+
+// Reasons of the Paused and Drifted conditions
+const (
+	reasonPausedAnnotation = "Annotation"
+	reasonMaintenanceMode  = "MaintenanceMode"
+	reasonDriftDetected    = "DriftDetected"
+	reasonInSync           = "InSync"
+	reasonDriftCheckFailed = "CheckFailed"
+)
+
+// Return why writes to MinIO are paused for a resource, either its annotation or maintenance mode,
+// or an empty string when they are not
+func pausedReason(ctx context.Context, c client.Reader, obj metav1.Object) (string, error) {
+	maintenanceMode, err := readMaintenanceMode()
+	if err != nil {
+		return "", err
+	}
+	if !maintenanceMode {
+		if maintenanceMode, err = readMaintenanceConfigMap(ctx, c); err != nil {
+			return "", err
+		}
+	}
+	if maintenanceMode {
+		return reasonMaintenanceMode, nil
+	}
+
+	if paused, _ := strconv.ParseBool(obj.GetAnnotations()[pausedAnnotation]); paused {
+		return reasonPausedAnnotation, nil
+	}
+
+	return "", nil
+}
+
+// Set the Paused condition and report the differences between MinIO and the spec in the Drifted condition.
+// The drift is unknown when checking it failed with err.
+func setPausedConditions(conditions *[]metav1.Condition, generation int64, reason string, drift []string, err error) {
+	message := "Writes to MinIO are paused by the " + pausedAnnotation + " annotation"
+	if reason == reasonMaintenanceMode {
+		message = "Writes to MinIO are paused by maintenance mode"
+	}
+	meta.SetStatusCondition(conditions, metav1.Condition{
+		Type:               conditionPaused,
+		Status:             metav1.ConditionTrue,
+		Reason:             reason,
+		Message:            message,
+		ObservedGeneration: generation,
+	})
+
+	drifted := metav1.Condition{
+		Type:               conditionDrifted,
+		Status:             metav1.ConditionFalse,
+		Reason:             reasonInSync,
+		Message:            "MinIO matches the spec",
+		ObservedGeneration: generation,
+	}
+	if err != nil {
+		drifted.Status = metav1.ConditionUnknown
+		drifted.Reason = reasonDriftCheckFailed
+		drifted.Message = err.Error()
+	} else if len(drift) > 0 {
+		drifted.Status = metav1.ConditionTrue
+		drifted.Reason = reasonDriftDetected
+		drifted.Message = strings.Join(drift, "; ")
+	}
+	meta.SetStatusCondition(conditions, drifted)
+}
+
+// Remove the conditions of a paused resource once it is resumed, returning whether there were any
+func clearPausedConditions(conditions *[]metav1.Condition) bool {
+	if meta.FindStatusCondition(*conditions, conditionPaused) == nil {
+		return false
+	}
+
+	meta.RemoveStatusCondition(conditions, conditionPaused)
+	meta.RemoveStatusCondition(conditions, conditionDrifted)
+	return true
+}
+
+func readMaintenanceMode() (bool, error) {
+	maintenanceMode := false
+
+	maintenanceModeString, found := os.LookupEnv(envMaintenanceMode)
+	if found {
+		maintenanceModeParsed, err := strconv.ParseBool(maintenanceModeString)
+		if err != nil {
+			return false, fmt.Errorf("%s must be either true or false", envMaintenanceMode)
+		}
+		maintenanceMode = maintenanceModeParsed
+	}
+
+	return maintenanceMode, nil
+}
+
+// Read maintenance mode from its ConfigMap, which is checked on every reconcile. Maintenance mode is
+// off when the ConfigMap or its key are missing.
+func readMaintenanceConfigMap(ctx context.Context, c client.Reader) (bool, error) {
+	name := defaultMaintenanceConfigMap
+	if value, found := os.LookupEnv(envMaintenanceConfigMap); found {
+		name = value
+	}
+	namespace := defaultMaintenanceNamespace
+	if value, found := os.LookupEnv(envMaintenanceConfigMapNamespace); found {
+		namespace = value
+	}
+
+	configMap := &corev1.ConfigMap{}
+	err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, configMap)
+	if apierrors.IsNotFound(err) {
+		return false, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to get configmap %s/%s: %w", namespace, name, err)
+	}
+
+	value, found := configMap.Data[maintenanceModeKey]
+	if !found {
+		return false, nil
+	}
+	maintenanceMode, err := strconv.ParseBool(value)
+	if err != nil {
+		return false, fmt.Errorf("%s in configmap %s/%s must be either true or false", maintenanceModeKey, namespace, name)
+	}
+
+	return maintenanceMode, nil
+}
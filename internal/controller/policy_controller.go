@@ -5,34 +5,52 @@
 package controller
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
 	"fmt"
+	"slices"
+
+	"github.com/minio/madmin-go/v3"
+	iampolicy "github.com/minio/pkg/iam/policy"
 
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
-	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
 )
 
 const policyFinalizer = "minio.scc-digitalhub.github.io/policy-finalizer"
 
+// Field index of Users by the policies in their spec
+const userPolicyIndex = "spec.policies"
+
+func indexUserPolicies(obj client.Object) []string {
+	return obj.(*operatorv1.User).Spec.Policies
+}
+
 // PolicyReconciler reconciles a Policy object
 type PolicyReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	Minio    MinioAPI
 }
 
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policies,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policies/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policies/finalizers,verbs=update
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=users,verbs=get;list;watch;update
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policyfragments,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=clusterpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=miniotenantpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
 // move the current state of the cluster closer to the desired state.
@@ -52,245 +70,219 @@ func (r *PolicyReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctr
 		return ctrl.Result{}, err
 	}
 
-	// If status is unknown, set Creating
-	if cr.Status.State == "" {
-		log.Info("State unspecified, updating to creating")
-		cr.Status.State = typeCreating
-		if err = r.Status().Update(ctx, cr); err != nil {
-			log.Error(err, genericStatusUpdateFailedMessage)
-			return ctrl.Result{}, err
-		}
-
-		return ctrl.Result{Requeue: true}, nil
-	}
-
-	// Create resource, if it doesn't exist
-	if cr.Status.State == typeCreating {
-		log.Info("Creating resource")
-
-		adminClient, err := getAdminClient()
-		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
+	return r.cannedPolicyReconciler().reconcile(ctx, r.cannedPolicy(cr))
+}
 
-		err = adminClient.AddCannedPolicy(context.Background(), cr.Spec.Name, []byte(cr.Spec.Content))
-		if err != nil {
-			log.Error(err, "Error while creating policy")
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
+func (r *PolicyReconciler) cannedPolicyReconciler() *cannedPolicyReconciler {
+	return &cannedPolicyReconciler{Client: r.Client, Recorder: r.Recorder, Minio: r.Minio}
+}
 
-		// Since MinIO may strip unused fields, retrieve its saved policy and overwrite ours
-		policyInfo, err := adminClient.InfoCannedPolicyV2(context.Background(), cr.Spec.Name)
-		if err != nil {
-			log.Error(err, "Failed to retrieve generated policy info")
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-		marshalled, err := json.Marshal(policyInfo.Policy)
-		if err != nil {
-			log.Error(err, "Failed to marshal generated policy info")
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-		cr.Spec.Content = string(marshalled[:])
-		r.Update(ctx, cr)
-
-		// Add finalizer
-		if !controllerutil.ContainsFinalizer(cr, policyFinalizer) {
-			log.Info("Adding finalizer for resource")
-			if ok := controllerutil.AddFinalizer(cr, policyFinalizer); !ok {
-				log.Error(err, "Failed to add finalizer to the custom resource")
-				return ctrl.Result{Requeue: true}, nil
+func (r *PolicyReconciler) cannedPolicy(cr *operatorv1.Policy) *cannedPolicy {
+	return &cannedPolicy{
+		obj:            cr,
+		kind:           "Policy",
+		name:           cr.Spec.Name,
+		deletionPolicy: cr.Spec.DeletionPolicy,
+		finalizer:      policyFinalizer,
+		status:         &cr.Status,
+		render: func(ctx context.Context) ([]byte, error) {
+			return renderPolicy(ctx, r.Client, cr)
+		},
+		check: func(ctx context.Context) error {
+			// Names of cluster policies are reserved, and cannot be taken over by namespaced ones
+			reserved, err := clusterPolicyExists(ctx, r.Client, cr.Spec.Name)
+			if err != nil {
+				return err
 			}
-
-			if err = r.Update(ctx, cr); err != nil {
-				log.Error(err, "Failed to update custom resource to add finalizer")
-				return ctrl.Result{}, err
+			if reserved {
+				return fmt.Errorf("%w: %s is a ClusterPolicy", errPolicyNameReserved, cr.Spec.Name)
 			}
 
-			if err := r.Get(ctx, req.NamespacedName, cr); err != nil {
-				log.Error(err, "Failed to re-fetch resource")
-				return ctrl.Result{}, err
+			// Resources violating the tenant policies of their namespace are not written to MinIO
+			if err := checkTenantPolicies(ctx, r.Client, cr); err != nil {
+				return err
 			}
-		}
 
-		cr.Status.State = typeReady
-		if err = r.Status().Update(ctx, cr); err != nil {
-			log.Error(err, genericStatusUpdateFailedMessage)
-			return ctrl.Result{}, err
-		}
+			// Nor are policies owned by another namespace
+			return checkNameClaim(ctx, r.Client, cr)
+		},
+		ownedElsewhere: func(ctx context.Context) (bool, error) {
+			// The policy in MinIO and the users holding it belong to the ClusterPolicy
+			reserved, err := clusterPolicyExists(ctx, r.Client, cr.Spec.Name)
+			if err != nil || reserved {
+				return reserved, err
+			}
 
-		return ctrl.Result{Requeue: true}, nil
+			// As do the ones of a policy owned by another namespace
+			owner, err := nameOwnedElsewhere(ctx, r.Client, cr)
+			return owner != "", err
+		},
 	}
+}
 
-	// Check if the instance is marked to be deleted, which is
-	// indicated by the deletion timestamp being set.
-	isMarkedToBeDeleted := cr.GetDeletionTimestamp() != nil
-	if isMarkedToBeDeleted {
-		log.Info("Resource marked to be deleted")
-		if controllerutil.ContainsFinalizer(cr, policyFinalizer) {
-			log.Info("Performing finalizer operations before deleting CR")
-
-			// Perform all operations required before removing the finalizer to allow
-			// the Kubernetes API to remove the custom resource.
-			if err := r.finalizerOpsForPolicy(cr); err != nil {
-				log.Error(err, "Finalizer operations failed")
-				return setPolicyErrorState(r, ctx, cr, err)
-			}
-
-			cr.Status.State = typeDegraded
+// SetupWithManager sets up the controller with the Manager.
+func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	// Also used by the PolicyCheck controller
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &operatorv1.User{}, userPolicyIndex, indexUserPolicies); err != nil {
+		return err
+	}
+	// As is the index of policies by name in MinIO
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &operatorv1.Policy{}, minioNameIndex, indexPolicyName); err != nil {
+		return err
+	}
 
-			if err := r.Status().Update(ctx, cr); err != nil {
-				log.Error(err, genericStatusUpdateFailedMessage)
-				return ctrl.Result{}, err
-			}
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1.Policy{}).
+		Watches(&source.Kind{Type: &operatorv1.User{}}, handler.EnqueueRequestsFromMapFunc(r.policiesForUser)).
+		Watches(&source.Kind{Type: &operatorv1.PolicyFragment{}}, handler.EnqueueRequestsFromMapFunc(r.policiesForFragment)).
+		Watches(&source.Kind{Type: &operatorv1.ClusterPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.policiesForClusterPolicy)).
+		Watches(&source.Kind{Type: &operatorv1.MinioTenantPolicy{}}, handler.EnqueueRequestsFromMapFunc(
+			resourcesForTenantPolicy(r.Client, func() client.ObjectList { return &operatorv1.PolicyList{} }))).
+		Complete(r)
+}
 
-			log.Info("Removing finalizer after successfully performing operations")
-			if ok := controllerutil.RemoveFinalizer(cr, policyFinalizer); !ok {
-				log.Error(err, "failed to remove finalizer")
-				return ctrl.Result{Requeue: true}, nil
-			}
+// Policies named like a ClusterPolicy, reconciled when it is created to stop managing the name
+func (r *PolicyReconciler) policiesForClusterPolicy(clusterPolicy client.Object) []reconcile.Request {
+	name := clusterPolicy.(*operatorv1.ClusterPolicy).Spec.Name
 
-			if err := r.Update(ctx, cr); err != nil {
-				log.Error(err, "failed to update resource")
-				return ctrl.Result{}, err
-			}
-		}
-		return ctrl.Result{}, nil
+	policies := &operatorv1.PolicyList{}
+	if err := r.List(context.Background(), policies); err != nil {
+		return nil
 	}
 
-	if cr.Status.State == typeReady {
-		log.Info("Resource in Ready state")
-		adminClient, err := getAdminClient()
-		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-
-		policyInfo, err := adminClient.InfoCannedPolicyV2(context.Background(), cr.Spec.Name)
-		if err != nil {
-			log.Error(err, "Failed to retrieve policy info")
-			return setPolicyErrorState(r, ctx, cr, err)
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if policy.Spec.Name == name {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
 		}
+	}
 
-		equivalent, err := equivalentPolicies(policyInfo.Policy, cr.Spec.Content)
-		if err != nil {
-			log.Error(err, "Failed to compare policies")
-			return setPolicyErrorState(r, ctx, cr, err)
-		} else if !equivalent {
-			cr.Status.State = typeUpdating
-			if err = r.Status().Update(ctx, cr); err != nil {
-				log.Error(err, genericStatusUpdateFailedMessage)
-				return ctrl.Result{}, err
-			}
-		}
+	return requests
+}
 
-		return ctrl.Result{}, nil
+// Policies including a PolicyFragment, reconciled when it changes
+func (r *PolicyReconciler) policiesForFragment(fragment client.Object) []reconcile.Request {
+	policies := &operatorv1.PolicyList{}
+	if err := r.List(context.Background(), policies, client.InNamespace(fragment.GetNamespace())); err != nil {
+		return nil
 	}
 
-	// Update resource
-	if cr.Status.State == typeUpdating {
-		log.Info("Updating resource")
-
-		// Update policy content
-		adminClient, err := getAdminClient()
-		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-		err = adminClient.AddCannedPolicy(context.Background(), cr.Spec.Name, []byte(cr.Spec.Content))
-		if err != nil {
-			log.Error(err, "Error while updating policy")
-			return setPolicyErrorState(r, ctx, cr, err)
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if slices.Contains(policy.Spec.Includes, fragment.GetName()) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
 		}
+	}
 
-		// Since MinIO may strip unused fields, retrieve its saved policy and overwrite ours
-		policyInfo, err := adminClient.InfoCannedPolicyV2(context.Background(), cr.Spec.Name)
-		if err != nil {
-			log.Error(err, "Failed to retrieve generated policy info")
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-		marshalled, err := json.Marshal(policyInfo.Policy)
-		if err != nil {
-			log.Error(err, "Failed to marshal generated policy info")
-			return setPolicyErrorState(r, ctx, cr, err)
-		}
-		cr.Spec.Content = string(marshalled[:])
-		r.Update(ctx, cr)
-
-		// Update status
-		cr.Status.State = typeReady
-		if err = r.Status().Update(ctx, cr); err != nil {
-			log.Error(err, genericStatusUpdateFailedMessage)
-			return ctrl.Result{}, err
-		}
+	return requests
+}
 
-		return ctrl.Result{}, nil
+// Policies referenced by a User, reconciled when it changes to keep their list of users current
+// and to resume deletions waiting for the user
+func (r *PolicyReconciler) policiesForUser(user client.Object) []reconcile.Request {
+	names := user.(*operatorv1.User).Spec.Policies
+	if len(names) == 0 {
+		return nil
 	}
 
-	// Error state
-	if cr.Status.State == typeError {
-		log.Info("Resource in error state")
-		return ctrl.Result{}, nil
+	policies := &operatorv1.PolicyList{}
+	if err := r.List(context.Background(), policies); err != nil {
+		return nil
 	}
 
-	return ctrl.Result{}, nil
-}
+	var requests []reconcile.Request
+	for _, policy := range policies.Items {
+		if slices.Contains(names, policy.Spec.Name) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&policy)})
+		}
+	}
 
-// SetupWithManager sets up the controller with the Manager.
-func (r *PolicyReconciler) SetupWithManager(mgr ctrl.Manager) error {
-	return ctrl.NewControllerManagedBy(mgr).
-		For(&operatorv1.Policy{}).
-		Complete(r)
+	return requests
 }
 
-func setPolicyErrorState(r *PolicyReconciler, ctx context.Context, cr *operatorv1.Policy, err error) (ctrl.Result, error) {
-	log := log.FromContext(ctx)
+// Users in any namespace referencing the policy, except the ones being deleted
+func usersReferencingPolicy(ctx context.Context, c client.Reader, name string) ([]operatorv1.User, error) {
+	users := &operatorv1.UserList{}
+	if err := c.List(ctx, users, client.MatchingFields{userPolicyIndex: name}); err != nil {
+		return nil, err
+	}
 
-	cr.Status.State = typeError
-	cr.Status.Message = err.Error()
+	return slices.DeleteFunc(users.Items, func(user operatorv1.User) bool {
+		return user.DeletionTimestamp != nil
+	}), nil
+}
 
-	if err := r.Status().Update(ctx, cr); err != nil {
-		log.Error(err, genericStatusUpdateFailedMessage)
-		return ctrl.Result{}, err
+func namespacedNames(users []operatorv1.User) []string {
+	var names []string
+	for _, user := range users {
+		names = append(names, user.Namespace+"/"+user.Name)
 	}
+	slices.Sort(names)
 
-	return ctrl.Result{}, err
+	return names
 }
 
-// Perform required operations before deleting the CR
-func (r *PolicyReconciler) finalizerOpsForPolicy(cr *operatorv1.Policy) error {
-	adminClient, err := getAdminClient()
-	if err != nil {
-		return err
+// Remove a policy from a user's spec and from the user in MinIO
+func detachPolicyFromUser(ctx context.Context, c client.Client, minioAPI MinioAPI, policy string, user *operatorv1.User) error {
+	user.Spec.Policies = slices.DeleteFunc(user.Spec.Policies, func(name string) bool {
+		return name == policy
+	})
+	if err := c.Update(ctx, user); err != nil {
+		return fmt.Errorf("failed to detach policy from user %s/%s: %w", user.Namespace, user.Name, err)
 	}
 
-	err = adminClient.RemoveCannedPolicy(context.Background(), cr.Spec.Name)
-	if err != nil {
+	err := minioAPI.DetachPolicy(ctx, madmin.PolicyAssociationReq{
+		Policies: []string{policy},
+		User:     user.Spec.AccessKey,
+	})
+	if err != nil && !isPolicyChangeAlreadyApplied(err) && !isNoSuchUser(err) {
 		return err
 	}
 
-	// The following implementation will raise an event
-	r.Recorder.Event(cr, "Warning", "Deleting",
-		fmt.Sprintf("Custom Resource %s is being deleted from the namespace %s",
-			cr.Name,
-			cr.Namespace))
-
 	return nil
 }
 
+// Whether a ClusterPolicy manages the policy with the given name in MinIO
+func clusterPolicyExists(ctx context.Context, c client.Reader, name string) (bool, error) {
+	policies := &operatorv1.ClusterPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return false, err
+	}
+
+	return slices.ContainsFunc(policies.Items, func(policy operatorv1.ClusterPolicy) bool {
+		return policy.Spec.Name == name
+	}), nil
+}
+
+// Compare the policy stored by MinIO with the spec, regardless of how either is serialized
 func equivalentPolicies(currentPolicy json.RawMessage, newPolicy string) (bool, error) {
-	// Marshal current policy
-	currentPolicyMarshalled, err := json.Marshal(currentPolicy)
+	current, err := parsePolicy(currentPolicy)
 	if err != nil {
 		return false, err
 	}
 
-	// Compact new policy
-	newPolicyBuffer := new(bytes.Buffer)
-	err = json.Compact(newPolicyBuffer, []byte(newPolicy))
+	desired, err := parsePolicy([]byte(newPolicy))
 	if err != nil {
 		return false, err
 	}
+	// MinIO stores policies without a version with the default one
+	if desired.Version == "" {
+		desired.Version = iampolicy.DefaultVersion
+	}
+	if current.ID != desired.ID || current.Version != desired.Version {
+		return false, nil
+	}
+
+	// Statements are compared as a set, their order does not change what a policy allows.
+	// Duplicates are dropped when parsing.
+	if len(current.Statements) != len(desired.Statements) {
+		return false, nil
+	}
+	for _, statement := range desired.Statements {
+		if !slices.ContainsFunc(current.Statements, statement.Equals) {
+			return false, nil
+		}
+	}
 
-	return bytes.Equal(newPolicyBuffer.Bytes(), currentPolicyMarshalled), nil
+	return true, nil
 }
@@ -0,0 +1,438 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"strings"
+	"testing"
+
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const testPolicyContent = `{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Allow",
+			"Action": ["s3:GetObject"],
+			"Resource": ["arn:aws:s3:::test-bucket/*"]
+		}
+	]
+}`
+
+func newTestPolicy() *operatorv1.Policy {
+	return &operatorv1.Policy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-policy", Namespace: "default"},
+		Spec: operatorv1.PolicySpec{
+			Name:    "test-policy",
+			Content: testPolicyContent,
+		},
+	}
+}
+
+func newTestPolicyReconciler(t *testing.T, cr *operatorv1.Policy, objs ...client.Object) (*PolicyReconciler, *fakeMinio) {
+	minio := newFakeMinio()
+	c := newFakeClient(t, append(objs, cr)...)
+	return &PolicyReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+		Minio:    minio,
+	}, minio
+}
+
+func TestPolicyReconcileCreate(t *testing.T) {
+	cr := newTestPolicy()
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+	if !controllerutil.ContainsFinalizer(cr, policyFinalizer) {
+		t.Fatal("expected finalizer to be added")
+	}
+
+	policy, found := minio.policies["test-policy"]
+	if !found {
+		t.Fatal("expected policy to be created")
+	}
+	equivalent, err := equivalentPolicies(policy, testPolicyContent)
+	if err != nil || !equivalent {
+		t.Fatalf("unexpected policy content %s", policy)
+	}
+}
+
+func TestPolicyReconcileCreateError(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Spec.Content = "not a policy"
+	cr.Status.State = typeCreating
+	r, _ := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if condition == nil || condition.Reason != string(errorClassPermanent) {
+		t.Fatalf("unexpected condition %+v", condition)
+	}
+}
+
+func TestPolicyReconcileContentDrift(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// Policy changed out of band
+	minio.policies["test-policy"] = []byte(`{"Version":"2012-10-17","Statement":[]}`)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeUpdating {
+		t.Fatalf("expected state %s, got %s", typeUpdating, cr.Status.State)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+	equivalent, err := equivalentPolicies(minio.policies["test-policy"], testPolicyContent)
+	if err != nil || !equivalent {
+		t.Fatalf("expected policy to be restored, got %s", minio.policies["test-policy"])
+	}
+}
+
+func TestPolicyReconcileReordered(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// MinIO serializes the same policy differently
+	minio.policies["test-policy"] = []byte(`{"Statement":[{"Resource":"arn:aws:s3:::test-bucket/*","Action":"s3:GetObject","Effect":"Allow"}],"Version":"2012-10-17"}`)
+	addCalls := minio.calls["AddCannedPolicy"]
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady || minio.calls["AddCannedPolicy"] != addCalls {
+		t.Fatalf("expected equivalent policy not to be updated, got state %s", cr.Status.State)
+	}
+	if cr.Spec.Content != testPolicyContent {
+		t.Fatalf("expected spec not to be changed, got %s", cr.Spec.Content)
+	}
+}
+
+func TestEquivalentPolicies(t *testing.T) {
+	tests := []struct {
+		name       string
+		current    string
+		desired    string
+		equivalent bool
+	}{
+		{
+			// The order of statements does not change what a policy allows
+			name:       "reordered statements",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]},{"Effect":"Deny","Action":["s3:DeleteObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":["s3:DeleteObject"],"Resource":["arn:aws:s3:::a/*"]},{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			equivalent: true,
+		},
+		{
+			name:       "reordered actions and resources",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:PutObject","s3:GetObject"],"Resource":["arn:aws:s3:::b/*","arn:aws:s3:::a/*"]}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject","s3:GetObject"],"Resource":["arn:aws:s3:::a/*","arn:aws:s3:::b/*"]}]}`,
+			equivalent: true,
+		},
+		{
+			name:       "single values and condition values",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":["s3:*"],"Resource":["arn:aws:s3:::a/*"],"Condition":{"Bool":{"aws:SecureTransport":["false"]},"IpAddress":{"aws:SourceIp":["10.0.0.0/8","192.168.0.0/16"]}}}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":"s3:*","Resource":"arn:aws:s3:::a/*","Condition":{"IpAddress":{"aws:SourceIp":["192.168.0.0/16","10.0.0.0/8"]},"Bool":{"aws:SecureTransport":false}}}]}`,
+			equivalent: true,
+		},
+		{
+			name:       "different actions",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject","s3:PutObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			equivalent: false,
+		},
+		{
+			name:       "different effect",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			equivalent: false,
+		},
+		{
+			name:       "duplicated instead of missing statement",
+			current:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]},{"Effect":"Deny","Action":["s3:DeleteObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			desired:    `{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]},{"Effect":"Allow","Action":["s3:GetObject"],"Resource":["arn:aws:s3:::a/*"]}]}`,
+			equivalent: false,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			equivalent, err := equivalentPolicies([]byte(test.current), test.desired)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if equivalent != test.equivalent {
+				t.Fatalf("expected equivalent to be %t", test.equivalent)
+			}
+		})
+	}
+}
+
+func TestPolicyReconcileInvalidContent(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeReady
+	r, minio := newTestPolicyReconciler(t, cr)
+	minio.policies["test-policy"] = []byte(testPolicyContent)
+
+	refetch(t, r.Client, cr)
+	cr.Spec.Content = `{"Statement":[`
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	// Invalid contents are not retried until the resource changes
+	if result := reconcileOnce(t, r, cr, false); result.Requeue || result.RequeueAfter > 0 {
+		t.Fatalf("expected no requeue, got %+v", result)
+	}
+	refetch(t, r.Client, cr)
+	condition := meta.FindStatusCondition(cr.Status.Conditions, conditionSynced)
+	if cr.Status.State != typeError || condition == nil || condition.Reason != string(errorClassPermanent) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+}
+
+func TestPolicyReconcilePaused(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	cr.Annotations = map[string]string{pausedAnnotation: "true"}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	minio.policies["test-policy"] = []byte(`{"Version":"2012-10-17","Statement":[]}`)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if !meta.IsStatusConditionTrue(cr.Status.Conditions, conditionDrifted) || cr.Status.State != typeReady {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if minio.calls["AddCannedPolicy"] != 1 {
+		t.Fatalf("expected policy not to be written while paused, got %d calls", minio.calls["AddCannedPolicy"])
+	}
+}
+
+func TestPolicyReconcileDelete(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.policies["test-policy"]; found {
+		t.Fatal("expected policy to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Policy{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestPolicyReconcileUsers(t *testing.T) {
+	cr := newTestPolicy()
+	other := newTestUser("test-policy")
+	other.Name, other.Namespace = "other-user", "other"
+	unrelated := newTestUser("readonly")
+	unrelated.Name = "unrelated-user"
+	r, _ := newTestPolicyReconciler(t, cr, newTestUser("test-policy"), other, unrelated)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	expected := []string{"default/test-user", "other/other-user"}
+	if !slices.Equal(cr.Status.Users, expected) {
+		t.Fatalf("expected users %v, got %v", expected, cr.Status.Users)
+	}
+
+	requests := r.policiesForUser(other)
+	if len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
+
+func TestPolicyReconcileDeleteInUse(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	user := newTestUser("test-policy")
+	r, minio := newTestPolicyReconciler(t, cr, user)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || !strings.Contains(cr.Status.Message, "default/test-user") {
+		t.Fatalf("expected deletion to be blocked, got %+v", cr.Status)
+	}
+	if _, found := minio.policies["test-policy"]; !found {
+		t.Fatal("expected policy not to be removed")
+	}
+
+	// Deleted once no longer referenced
+	refetch(t, r.Client, user)
+	user.Spec.Policies = nil
+	if err := r.Update(context.Background(), user); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.policies["test-policy"]; found {
+		t.Fatal("expected policy to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.Policy{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestPolicyReconcileDeleteDetach(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Spec.DeletionPolicy = operatorv1.PolicyDeletionDetach
+	cr.Status.State = typeCreating
+	user := newTestUser("test-policy", "readonly")
+	r, minio := newTestPolicyReconciler(t, cr, user)
+	minio.users["test-user"] = &fakeUser{secretKey: "test-secret-key", policies: []string{"test-policy", "readonly"}}
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, user)
+	if !slices.Equal(user.Spec.Policies, []string{"readonly"}) {
+		t.Fatalf("expected policy to be removed from user, got %v", user.Spec.Policies)
+	}
+	if !slices.Equal(minio.users["test-user"].policies, []string{"readonly"}) {
+		t.Fatalf("expected policy to be detached, got %v", minio.users["test-user"].policies)
+	}
+	if _, found := minio.policies["test-policy"]; found {
+		t.Fatal("expected policy to be removed")
+	}
+}
+
+func TestPolicyReconcileIncludes(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Spec.Includes = []string{"deny-delete"}
+	fragment := &operatorv1.PolicyFragment{
+		ObjectMeta: metav1.ObjectMeta{Name: "deny-delete", Namespace: "default"},
+		Spec:       operatorv1.PolicyFragmentSpec{Content: testDenyDeletePolicyContent},
+	}
+	r, minio := newTestPolicyReconciler(t, cr, fragment)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	policy, err := parsePolicy(minio.policies["test-policy"])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(policy.Statements) != 2 || policy.Statements[1].Effect != "Deny" {
+		t.Fatalf("expected fragment statements to be merged, got %s", minio.policies["test-policy"])
+	}
+
+	// Changing the fragment updates the policies including it
+	requests := r.policiesForFragment(fragment)
+	if len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	refetch(t, r.Client, fragment)
+	fragment.Spec.Content = `{"Statement":[]}`
+	if err := r.Update(context.Background(), fragment); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	equivalent, err := equivalentPolicies(minio.policies["test-policy"], testPolicyContent)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !equivalent {
+		t.Fatalf("expected policy to be updated, got %s", minio.policies["test-policy"])
+	}
+}
+
+func TestPolicyReconcileMissingFragment(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Spec.Includes = []string{"missing"}
+	cr.Status.State = typeCreating
+	r, minio := newTestPolicyReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || !strings.Contains(cr.Status.Message, "missing") {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if _, found := minio.policies["test-policy"]; found {
+		t.Fatal("expected policy not to be created")
+	}
+}
+
+func TestPolicyReconcileReservedName(t *testing.T) {
+	cr := newTestPolicy()
+	cr.Status.State = typeCreating
+	clusterPolicy := newTestClusterPolicy()
+	r, minio := newTestPolicyReconciler(t, cr, clusterPolicy)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || !strings.Contains(cr.Status.Message, errPolicyNameReserved.Error()) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if minio.calls["AddCannedPolicy"] != 0 {
+		t.Fatal("expected policy not to be written")
+	}
+
+	requests := r.policiesForClusterPolicy(clusterPolicy)
+	if len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+}
@@ -0,0 +1,70 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/minio/pkg/bucket/policy/condition"
+	iampolicy "github.com/minio/pkg/iam/policy"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func parsePolicy(policy []byte) (iampolicy.Policy, error) {
+	document, err := iampolicy.ParseConfig(bytes.NewReader(policy))
+	if err != nil {
+		return iampolicy.Policy{}, fmt.Errorf("%w: %w", errInvalidPolicy, err)
+	}
+
+	return *document, nil
+}
+
+// Decide whether the policies of a user allow an action on a resource, the way MinIO does:
+// an explicit Deny wins over any Allow, and nothing is allowed unless a statement allows it.
+// Conditions are evaluated against the given values, keyed like in policies, e.g. aws:SourceIp.
+func evaluatePolicies(policies []iampolicy.Policy, accessKey string, conditions map[string][]string, request operatorv1.AccessRequest) string {
+	merged := iampolicy.Policy{Version: iampolicy.DefaultVersion}
+	for _, policy := range policies {
+		merged = merged.Merge(policy)
+	}
+
+	args := accessArgs(accessKey, conditions, request)
+	denyOnly := args
+	denyOnly.DenyOnly = true
+
+	switch {
+	case !merged.IsAllowed(denyOnly):
+		return operatorv1.DecisionDeny
+	case merged.IsAllowed(args):
+		return operatorv1.DecisionAllow
+	}
+	return operatorv1.DecisionImplicitDeny
+}
+
+// Arguments of a request as MinIO builds them, with the user name variables set to the access key
+func accessArgs(accessKey string, conditions map[string][]string, request operatorv1.AccessRequest) iampolicy.Args {
+	values := map[string][]string{}
+	if accessKey != "" {
+		values[condition.AWSUsername.Name()] = []string{accessKey}
+		values[condition.AWSUserID.Name()] = []string{accessKey}
+	}
+	for key, value := range conditions {
+		values[condition.KeyName(key).Name()] = value
+	}
+
+	resource := strings.TrimPrefix(request.Resource, iampolicy.ResourceARNPrefix)
+	bucket, object, _ := strings.Cut(resource, "/")
+
+	return iampolicy.Args{
+		AccountName:     accessKey,
+		Action:          iampolicy.Action(request.Action),
+		BucketName:      bucket,
+		ObjectName:      object,
+		ConditionValues: values,
+	}
+}
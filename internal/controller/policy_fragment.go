@@ -0,0 +1,65 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Content of a policy as written to MinIO, with the statements of its fragments
+func renderPolicy(ctx context.Context, c client.Reader, cr *operatorv1.Policy) ([]byte, error) {
+	if len(cr.Spec.Includes) == 0 {
+		return []byte(cr.Spec.Content), nil
+	}
+
+	fragments := make([]operatorv1.PolicyFragment, len(cr.Spec.Includes))
+	for i, name := range cr.Spec.Includes {
+		if err := c.Get(ctx, types.NamespacedName{Name: name, Namespace: cr.Namespace}, &fragments[i]); err != nil {
+			return nil, fmt.Errorf("failed to get policy fragment %s: %w", name, err)
+		}
+	}
+
+	return mergeStatements(cr.Spec.Content, fragments)
+}
+
+// Append the statements of the fragments to the ones of the policy, keeping its other fields
+func mergeStatements(content string, fragments []operatorv1.PolicyFragment) ([]byte, error) {
+	document := map[string]json.RawMessage{}
+	if err := json.Unmarshal([]byte(content), &document); err != nil {
+		return nil, fmt.Errorf("%w: %w", errInvalidPolicy, err)
+	}
+
+	var statements []json.RawMessage
+	if raw, found := document["Statement"]; found {
+		if err := json.Unmarshal(raw, &statements); err != nil {
+			return nil, fmt.Errorf("%w statements: %w", errInvalidPolicy, err)
+		}
+	}
+
+	for _, fragment := range fragments {
+		var included struct {
+			Statement []json.RawMessage
+		}
+		if err := json.Unmarshal([]byte(fragment.Spec.Content), &included); err != nil {
+			return nil, fmt.Errorf("%w fragment %s: %w", errInvalidPolicy, fragment.Name, err)
+		}
+		statements = append(statements, included.Statement...)
+	}
+
+	raw, err := json.Marshal(statements)
+	if err != nil {
+		return nil, err
+	}
+	document["Statement"] = raw
+
+	return json.Marshal(document)
+}
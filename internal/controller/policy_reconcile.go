@@ -0,0 +1,358 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// cannedPolicy is a Policy or a ClusterPolicy, both managing a canned policy in MinIO
+type cannedPolicy struct {
+	obj client.Object
+	// Kind of the resource, used in metrics
+	kind           string
+	name           string
+	deletionPolicy string
+	finalizer      string
+	status         *operatorv1.PolicyStatus
+	// Render the content written to MinIO
+	render func(ctx context.Context) ([]byte, error)
+	// Check that the resource may write the policy to MinIO
+	check func(ctx context.Context) error
+	// Whether the policy in MinIO and the users holding it belong to another resource, and are
+	// left alone when the resource is deleted
+	ownedElsewhere func(ctx context.Context) (bool, error)
+}
+
+// Resource name as shown in events, with the namespace of namespaced resources
+func (p *cannedPolicy) displayName() string {
+	if p.obj.GetNamespace() == "" {
+		return p.obj.GetName()
+	}
+	return p.obj.GetNamespace() + "/" + p.obj.GetName()
+}
+
+// cannedPolicyReconciler holds the reconcile loop shared by the Policy and ClusterPolicy reconcilers
+type cannedPolicyReconciler struct {
+	client.Client
+	Recorder record.EventRecorder
+	Minio    MinioAPI
+}
+
+func (r *cannedPolicyReconciler) reconcile(ctx context.Context, cr *cannedPolicy) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	// Paused resources are only checked for drift, without writing to MinIO
+	paused, err := pausedReason(ctx, r.Client, cr.obj)
+	if err != nil {
+		log.Error(err, "Failed to check if reconciliation is paused")
+		return ctrl.Result{}, err
+	}
+	if paused != "" {
+		return r.reportDrift(ctx, cr, paused)
+	}
+	if clearPausedConditions(&cr.status.Conditions) {
+		log.Info("Resuming reconciliation")
+		if err = r.Status().Update(ctx, cr.obj); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// If status is unknown, set Creating
+	if cr.status.State == "" {
+		log.Info("State unspecified, updating to creating")
+		cr.status.State = typeCreating
+		if err = r.Status().Update(ctx, cr.obj); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	if cr.obj.GetDeletionTimestamp() == nil && cr.status.State != typeError {
+		if err := cr.check(ctx); err != nil {
+			log.Error(err, "Policy may not be written to MinIO")
+			return r.setErrorState(ctx, cr, err)
+		}
+	}
+
+	// Create resource, if it doesn't exist
+	if cr.status.State == typeCreating {
+		log.Info("Creating resource")
+
+		content, err := cr.render(ctx)
+		if err != nil {
+			log.Error(err, "Failed to render policy")
+			return r.setErrorState(ctx, cr, err)
+		}
+
+		err = r.Minio.AddCannedPolicy(ctx, cr.name, content)
+		if err != nil {
+			log.Error(err, "Error while creating policy")
+			return r.setErrorState(ctx, cr, err)
+		}
+
+		// Add finalizer
+		if !controllerutil.ContainsFinalizer(cr.obj, cr.finalizer) {
+			log.Info("Adding finalizer for resource")
+			if ok := controllerutil.AddFinalizer(cr.obj, cr.finalizer); !ok {
+				log.Error(err, "Failed to add finalizer to the custom resource")
+				return ctrl.Result{Requeue: true}, nil
+			}
+
+			if err = r.Update(ctx, cr.obj); err != nil {
+				log.Error(err, "Failed to update custom resource to add finalizer")
+				return ctrl.Result{}, err
+			}
+
+			if err := r.Get(ctx, client.ObjectKeyFromObject(cr.obj), cr.obj); err != nil {
+				log.Error(err, "Failed to re-fetch resource")
+				return ctrl.Result{}, err
+			}
+		}
+
+		cr.status.State = typeReady
+		cr.status.Message = ""
+		setSyncedCondition(&cr.status.Conditions, cr.obj.GetGeneration(), nil)
+		if err = r.Status().Update(ctx, cr.obj); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	// Check if the instance is marked to be deleted, which is
+	// indicated by the deletion timestamp being set.
+	isMarkedToBeDeleted := cr.obj.GetDeletionTimestamp() != nil
+	if isMarkedToBeDeleted {
+		log.Info("Resource marked to be deleted")
+		if controllerutil.ContainsFinalizer(cr.obj, cr.finalizer) {
+			log.Info("Performing finalizer operations before deleting CR")
+
+			// Perform all operations required before removing the finalizer to allow
+			// the Kubernetes API to remove the custom resource.
+			if err := trackFinalizer(cr.kind, func() error { return r.finalizerOps(ctx, cr) }); err != nil {
+				log.Error(err, "Finalizer operations failed")
+				return r.setErrorState(ctx, cr, err)
+			}
+
+			cr.status.State = typeDegraded
+
+			if err := r.Status().Update(ctx, cr.obj); err != nil {
+				log.Error(err, genericStatusUpdateFailedMessage)
+				return ctrl.Result{}, err
+			}
+
+			log.Info("Removing finalizer after successfully performing operations")
+			if ok := controllerutil.RemoveFinalizer(cr.obj, cr.finalizer); !ok {
+				log.Error(err, "failed to remove finalizer")
+				return ctrl.Result{Requeue: true}, nil
+			}
+
+			if err := r.Update(ctx, cr.obj); err != nil {
+				log.Error(err, "failed to update resource")
+				return ctrl.Result{}, err
+			}
+		}
+		return ctrl.Result{}, nil
+	}
+
+	if cr.status.State == typeReady {
+		log.Info("Resource in Ready state")
+
+		users, err := usersReferencingPolicy(ctx, r.Client, cr.name)
+		if err != nil {
+			log.Error(err, "Failed to list users referencing the policy")
+			return ctrl.Result{}, err
+		}
+		if names := namespacedNames(users); !slices.Equal(names, cr.status.Users) {
+			cr.status.Users = names
+			if err = r.Status().Update(ctx, cr.obj); err != nil {
+				log.Error(err, genericStatusUpdateFailedMessage)
+				return ctrl.Result{}, err
+			}
+		}
+
+		drift, err := r.detectDrift(ctx, cr)
+		if err != nil {
+			log.Error(err, "Failed to compare policies")
+			return r.setErrorState(ctx, cr, err)
+		} else if len(drift) > 0 {
+			recordDriftCorrection(cr.kind)
+			cr.status.State = typeUpdating
+			if err = r.Status().Update(ctx, cr.obj); err != nil {
+				log.Error(err, genericStatusUpdateFailedMessage)
+				return ctrl.Result{}, err
+			}
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// Update resource
+	if cr.status.State == typeUpdating {
+		log.Info("Updating resource")
+
+		// Update policy content
+		content, err := cr.render(ctx)
+		if err != nil {
+			log.Error(err, "Failed to render policy")
+			return r.setErrorState(ctx, cr, err)
+		}
+
+		err = r.Minio.AddCannedPolicy(ctx, cr.name, content)
+		if err != nil {
+			log.Error(err, "Error while updating policy")
+			return r.setErrorState(ctx, cr, err)
+		}
+
+		// Update status
+		cr.status.State = typeReady
+		cr.status.Message = ""
+		setSyncedCondition(&cr.status.Conditions, cr.obj.GetGeneration(), nil)
+		if err = r.Status().Update(ctx, cr.obj); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{}, nil
+	}
+
+	// Error state
+	if cr.status.State == typeError {
+		log.Info("Resource in error state")
+
+		// Permanent errors are retried once the spec changes, any other on requeue
+		if !shouldRetryError(cr.status.Conditions, cr.obj.GetGeneration()) {
+			return ctrl.Result{}, nil
+		}
+
+		cr.status.State = typeCreating
+		if err = r.Status().Update(ctx, cr.obj); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
+	return ctrl.Result{}, nil
+}
+
+func (r *cannedPolicyReconciler) setErrorState(ctx context.Context, cr *cannedPolicy, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr.status.State = typeError
+	cr.status.Message = err.Error()
+	setSyncedCondition(&cr.status.Conditions, cr.obj.GetGeneration(), err)
+
+	if err := r.Status().Update(ctx, cr.obj); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return resultForError(err)
+}
+
+// Perform required operations before deleting the CR
+func (r *cannedPolicyReconciler) finalizerOps(ctx context.Context, cr *cannedPolicy) error {
+	ownedElsewhere, err := cr.ownedElsewhere(ctx)
+	if err != nil || ownedElsewhere {
+		return err
+	}
+
+	users, err := usersReferencingPolicy(ctx, r.Client, cr.name)
+	if err != nil {
+		return err
+	}
+
+	if len(users) > 0 && cr.deletionPolicy != operatorv1.PolicyDeletionDetach {
+		names := strings.Join(namespacedNames(users), ", ")
+		r.Recorder.Event(cr.obj, "Warning", "PolicyInUse",
+			fmt.Sprintf("Policy %s is referenced by users %s: remove it from them or set deletionPolicy to %s",
+				cr.name, names, operatorv1.PolicyDeletionDetach))
+		return fmt.Errorf("%w: referenced by users %s", errPolicyInUse, names)
+	}
+
+	for i := range users {
+		if err := detachPolicyFromUser(ctx, r.Client, r.Minio, cr.name, &users[i]); err != nil {
+			return err
+		}
+		r.Recorder.Event(&users[i], "Warning", "PolicyDetached",
+			fmt.Sprintf("Policy %s was detached since its resource %s is being deleted", cr.name, cr.displayName()))
+	}
+
+	err = r.Minio.RemoveCannedPolicy(ctx, cr.name)
+	if err != nil && !isNoSuchPolicy(err) {
+		return err
+	}
+
+	// The following implementation will raise an event
+	r.Recorder.Event(cr.obj, "Warning", "Deleting",
+		fmt.Sprintf("Custom Resource %s is being deleted", cr.displayName()))
+
+	return nil
+}
+
+// Report how the policy in MinIO differs from the spec, without changing it
+func (r *cannedPolicyReconciler) reportDrift(ctx context.Context, cr *cannedPolicy, reason string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciliation paused, checking drift", "reason", reason)
+
+	drift, err := r.detectDrift(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to check drift")
+	}
+
+	setPausedConditions(&cr.status.Conditions, cr.obj.GetGeneration(), reason, drift, err)
+	if err := r.Status().Update(ctx, cr.obj); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: pausedDriftCheckInterval}, nil
+}
+
+// Return the differences between the policy in MinIO and the spec
+func (r *cannedPolicyReconciler) detectDrift(ctx context.Context, cr *cannedPolicy) ([]string, error) {
+	policyInfo, err := r.Minio.InfoCannedPolicyV2(ctx, cr.name)
+	if isNoSuchPolicy(err) {
+		return []string{"policy does not exist"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	content, err := cr.render(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	equivalent, err := equivalentPolicies(policyInfo.Policy, string(content))
+	if err != nil {
+		return nil, err
+	}
+	if !equivalent {
+		return []string{"content differs"}, nil
+	}
+
+	return nil, nil
+}
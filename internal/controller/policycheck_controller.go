@@ -0,0 +1,449 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+
+	iampolicy "github.com/minio/pkg/iam/policy"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/builder"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/predicate"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// PolicyCheckReconciler evaluates PolicyCheck resources against the policies stored by MinIO,
+// without changing anything in MinIO
+type PolicyCheckReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Minio    MinioAPI
+}
+
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policychecks,verbs=get;list;watch;create;update;patch;delete
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policychecks/status,verbs=get;update;patch
+
+func (r *PolicyCheckReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr := &operatorv1.PolicyCheck{}
+	err := r.Get(ctx, req.NamespacedName, cr)
+	if err != nil {
+		if apierrors.IsNotFound(err) {
+			// If the custom resource is not found, it usually means that it was deleted or not created
+			log.Info("resource not found; ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+		// Error reading the object - requeue the request.
+		log.Error(err, "Failed to get resource")
+		return ctrl.Result{}, err
+	}
+
+	log.Info("Evaluating policy check")
+	policies := map[string]iampolicy.Policy{}
+
+	results, err := r.evaluateRequests(ctx, cr, policies)
+	if err != nil {
+		log.Error(err, "Failed to evaluate requests")
+		return setPolicyCheckErrorState(r, ctx, cr, err)
+	}
+
+	changes, err := r.policyChanges(ctx, cr, policies)
+	if err != nil {
+		log.Error(err, "Failed to evaluate policy changes")
+		return setPolicyCheckErrorState(r, ctx, cr, err)
+	}
+
+	cr.Status.State = typeReady
+	cr.Status.Message = ""
+	cr.Status.ObservedGeneration = cr.Generation
+	cr.Status.Results = results
+	cr.Status.Changes = changes
+	setSyncedCondition(&cr.Status.Conditions, cr.Generation, nil)
+	if err = r.Status().Update(ctx, cr); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{}, nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// Checks are evaluated again when their spec or the resources they reference change.
+func (r *PolicyCheckReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&operatorv1.PolicyCheck{}, builder.WithPredicates(predicate.GenerationChangedPredicate{})).
+		Watches(&source.Kind{Type: &operatorv1.User{}}, handler.EnqueueRequestsFromMapFunc(r.checksForUser)).
+		Watches(&source.Kind{Type: &operatorv1.Policy{}}, handler.EnqueueRequestsFromMapFunc(r.checksForPolicy)).
+		Watches(&source.Kind{Type: &operatorv1.PolicyFragment{}}, handler.EnqueueRequestsFromMapFunc(r.checksForFragment)).
+		Watches(&source.Kind{Type: &operatorv1.ClusterPolicy{}}, handler.EnqueueRequestsFromMapFunc(r.checksForClusterPolicy)).
+		Complete(r)
+}
+
+// Checks evaluating the access of a User
+func (r *PolicyCheckReconciler) checksForUser(user client.Object) []reconcile.Request {
+	return r.checksMatching(user.GetNamespace(), func(check *operatorv1.PolicyCheck) bool {
+		return check.Spec.User == user.GetName()
+	})
+}
+
+// Checks on a Policy, listing it by name or evaluating a User it is attached to
+func (r *PolicyCheckReconciler) checksForPolicy(obj client.Object) []reconcile.Request {
+	policy := obj.(*operatorv1.Policy)
+
+	users, err := r.usersReferencing(policy.Spec.Name)
+	if err != nil {
+		return nil
+	}
+
+	return r.checksMatching(policy.Namespace, func(check *operatorv1.PolicyCheck) bool {
+		return check.Spec.Policy == policy.Name ||
+			slices.Contains(check.Spec.Policies, policy.Spec.Name) ||
+			slices.Contains(users, check.Namespace+"/"+check.Spec.User)
+	})
+}
+
+// Checks comparing or listing a Policy including a PolicyFragment
+func (r *PolicyCheckReconciler) checksForFragment(fragment client.Object) []reconcile.Request {
+	policies := &operatorv1.PolicyList{}
+	if err := r.List(context.Background(), policies, client.InNamespace(fragment.GetNamespace())); err != nil {
+		return nil
+	}
+
+	var including, includingNames []string
+	for _, policy := range policies.Items {
+		if slices.Contains(policy.Spec.Includes, fragment.GetName()) {
+			including = append(including, policy.Name)
+			includingNames = append(includingNames, policy.Spec.Name)
+		}
+	}
+
+	return r.checksMatching(fragment.GetNamespace(), func(check *operatorv1.PolicyCheck) bool {
+		return slices.Contains(including, check.Spec.Policy) ||
+			slices.ContainsFunc(check.Spec.Policies, func(name string) bool {
+				return slices.Contains(includingNames, name)
+			})
+	})
+}
+
+// Checks in any namespace listing a ClusterPolicy or evaluating a User it is attached to
+func (r *PolicyCheckReconciler) checksForClusterPolicy(obj client.Object) []reconcile.Request {
+	name := obj.(*operatorv1.ClusterPolicy).Spec.Name
+
+	users, err := r.usersReferencing(name)
+	if err != nil {
+		return nil
+	}
+
+	return r.checksMatching("", func(check *operatorv1.PolicyCheck) bool {
+		return slices.Contains(check.Spec.Policies, name) ||
+			slices.Contains(users, check.Namespace+"/"+check.Spec.User)
+	})
+}
+
+func (r *PolicyCheckReconciler) usersReferencing(policyName string) ([]string, error) {
+	users, err := usersReferencingPolicy(context.Background(), r.Client, policyName)
+	if err != nil {
+		return nil, err
+	}
+
+	return namespacedNames(users), nil
+}
+
+func (r *PolicyCheckReconciler) checksMatching(namespace string, matches func(*operatorv1.PolicyCheck) bool) []reconcile.Request {
+	checks := &operatorv1.PolicyCheckList{}
+	if err := r.List(context.Background(), checks, client.InNamespace(namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for i := range checks.Items {
+		if matches(&checks.Items[i]) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&checks.Items[i])})
+		}
+	}
+
+	return requests
+}
+
+// Evaluate the requests against the policies of the user in MinIO and the listed ones
+func (r *PolicyCheckReconciler) evaluateRequests(ctx context.Context, cr *operatorv1.PolicyCheck, cache map[string]iampolicy.Policy) ([]operatorv1.AccessResult, error) {
+	if len(cr.Spec.Requests) == 0 {
+		return nil, nil
+	}
+	if cr.Spec.User == "" && len(cr.Spec.Policies) == 0 {
+		return nil, errors.New("requests need either a user or policies to be evaluated against")
+	}
+
+	policies, err := r.listedPolicies(ctx, cr)
+	if err != nil {
+		return nil, err
+	}
+
+	accessKey := ""
+	if cr.Spec.User != "" {
+		user := &operatorv1.User{}
+		if err := r.Get(ctx, types.NamespacedName{Name: cr.Spec.User, Namespace: cr.Namespace}, user); err != nil {
+			return nil, fmt.Errorf("failed to get user %s: %w", cr.Spec.User, err)
+		}
+		accessKey = user.Spec.AccessKey
+
+		attached, err := r.attachedPolicies(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		userPolicies, err := r.loadPolicies(ctx, attached, cache)
+		if err != nil {
+			return nil, err
+		}
+		policies = append(policies, userPolicies...)
+	}
+
+	results := make([]operatorv1.AccessResult, 0, len(cr.Spec.Requests))
+	for _, request := range cr.Spec.Requests {
+		results = append(results, operatorv1.AccessResult{
+			AccessRequest: request,
+			Decision:      evaluatePolicies(policies, accessKey, cr.Spec.Conditions, request),
+		})
+	}
+
+	return results, nil
+}
+
+// Render the policies listed by a check, which are either Policies of its namespace or
+// ClusterPolicies, named as in MinIO
+func (r *PolicyCheckReconciler) listedPolicies(ctx context.Context, cr *operatorv1.PolicyCheck) ([]iampolicy.Policy, error) {
+	var policies []iampolicy.Policy
+	for _, name := range cr.Spec.Policies {
+		content, err := r.renderListedPolicy(ctx, cr.Namespace, name)
+		if err != nil {
+			return nil, err
+		}
+		policy, err := parsePolicy(content)
+		if err != nil {
+			return nil, fmt.Errorf("policy %s: %w", name, err)
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+func (r *PolicyCheckReconciler) renderListedPolicy(ctx context.Context, namespace, name string) ([]byte, error) {
+	// Cluster policies take the name over namespaced ones, the one owning it when several do
+	clusterPolicies := &operatorv1.ClusterPolicyList{}
+	if err := r.List(ctx, clusterPolicies, client.MatchingFields{minioNameIndex: name}); err != nil {
+		return nil, err
+	}
+	for i := range clusterPolicies.Items {
+		owner, err := nameOwnedElsewhere(ctx, r.Client, &clusterPolicies.Items[i])
+		if err != nil {
+			return nil, err
+		}
+		if owner == "" {
+			return []byte(clusterPolicies.Items[i].Spec.Content), nil
+		}
+	}
+
+	policies := &operatorv1.PolicyList{}
+	if err := r.List(ctx, policies, client.InNamespace(namespace), client.MatchingFields{minioNameIndex: name}); err != nil {
+		return nil, err
+	}
+	if len(policies.Items) == 0 {
+		return nil, fmt.Errorf("policy %s is neither a Policy in namespace %s nor a ClusterPolicy", name, namespace)
+	}
+
+	return renderPolicy(ctx, r.Client, &policies.Items[0])
+}
+
+// Compare the access of the users a policy is attached to, before and after its spec is applied
+func (r *PolicyCheckReconciler) policyChanges(ctx context.Context, cr *operatorv1.PolicyCheck, cache map[string]iampolicy.Policy) ([]operatorv1.AccessChange, error) {
+	if cr.Spec.Policy == "" {
+		return nil, nil
+	}
+
+	policy := &operatorv1.Policy{}
+	if err := r.Get(ctx, types.NamespacedName{Name: cr.Spec.Policy, Namespace: cr.Namespace}, policy); err != nil {
+		return nil, fmt.Errorf("failed to get policy %s: %w", cr.Spec.Policy, err)
+	}
+	content, err := renderPolicy(ctx, r.Client, policy)
+	if err != nil {
+		return nil, err
+	}
+	desired, err := parsePolicy(content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Policies not created yet grant nothing
+	current := iampolicy.Policy{}
+	policyInfo, err := r.Minio.InfoCannedPolicyV2(ctx, policy.Spec.Name)
+	if err != nil && !isNoSuchPolicy(err) {
+		return nil, err
+	}
+	if err == nil {
+		if current, err = parsePolicy(policyInfo.Policy); err != nil {
+			return nil, err
+		}
+	}
+
+	requests := cr.Spec.Requests
+	if len(requests) == 0 {
+		requests = policyRequests(current, desired)
+	}
+
+	users := &operatorv1.UserList{}
+	if err := r.List(ctx, users, client.InNamespace(cr.Namespace), client.MatchingFields{userPolicyIndex: policy.Spec.Name}); err != nil {
+		return nil, err
+	}
+
+	var changes []operatorv1.AccessChange
+	for i := range users.Items {
+		user := &users.Items[i]
+
+		attached, err := r.attachedPolicies(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		others, err := r.loadPolicies(ctx, slices.DeleteFunc(attached, func(name string) bool {
+			return name == policy.Spec.Name
+		}), cache)
+		if err != nil {
+			return nil, err
+		}
+
+		before := append(slices.Clone(others), current)
+		after := append(slices.Clone(others), desired)
+		for _, request := range requests {
+			decisionBefore := evaluatePolicies(before, user.Spec.AccessKey, cr.Spec.Conditions, request)
+			decisionAfter := evaluatePolicies(after, user.Spec.AccessKey, cr.Spec.Conditions, request)
+			if decisionBefore != decisionAfter {
+				changes = append(changes, operatorv1.AccessChange{
+					AccessRequest: request,
+					User:          user.Name,
+					Before:        decisionBefore,
+					After:         decisionAfter,
+				})
+			}
+		}
+	}
+
+	return changes, nil
+}
+
+// Policies attached in MinIO to a user and to the groups it is a member of,
+// or the ones in its spec if it does not exist yet
+func (r *PolicyCheckReconciler) attachedPolicies(ctx context.Context, user *operatorv1.User) ([]string, error) {
+	userInfo, err := r.Minio.GetUserInfo(ctx, user.Spec.AccessKey)
+	if isNoSuchUser(err) {
+		return slices.Clone(user.Spec.Policies), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	names := splitPolicyNames(userInfo.PolicyName)
+	for _, group := range userInfo.MemberOf {
+		groupDesc, err := r.Minio.GetGroupDescription(ctx, group)
+		if err != nil {
+			return nil, fmt.Errorf("failed to get group %s: %w", group, err)
+		}
+		for _, name := range splitPolicyNames(groupDesc.Policy) {
+			if !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		}
+	}
+
+	return names, nil
+}
+
+func splitPolicyNames(policyName string) []string {
+	var names []string
+	for _, name := range strings.Split(policyName, ",") {
+		if name != "" {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+// Read policies from MinIO, once per reconcile
+func (r *PolicyCheckReconciler) loadPolicies(ctx context.Context, names []string, cache map[string]iampolicy.Policy) ([]iampolicy.Policy, error) {
+	policies := make([]iampolicy.Policy, 0, len(names))
+	for _, name := range names {
+		policy, found := cache[name]
+		if !found {
+			policyInfo, err := r.Minio.InfoCannedPolicyV2(ctx, name)
+			if err != nil {
+				return nil, fmt.Errorf("failed to get policy %s: %w", name, err)
+			}
+			if policy, err = parsePolicy(policyInfo.Policy); err != nil {
+				return nil, err
+			}
+			cache[name] = policy
+		}
+		policies = append(policies, policy)
+	}
+
+	return policies, nil
+}
+
+// Requests for every action on every resource named by the statements of the policies
+func policyRequests(policies ...iampolicy.Policy) []operatorv1.AccessRequest {
+	var requests []operatorv1.AccessRequest
+	for _, policy := range policies {
+		for _, statement := range policy.Statements {
+			for action := range statement.Actions {
+				for resource := range statement.Resources {
+					request := operatorv1.AccessRequest{Action: string(action), Resource: resource.String()}
+					if !slices.Contains(requests, request) {
+						requests = append(requests, request)
+					}
+				}
+			}
+		}
+	}
+	slices.SortFunc(requests, func(a, b operatorv1.AccessRequest) int {
+		if a.Action != b.Action {
+			return strings.Compare(a.Action, b.Action)
+		}
+		return strings.Compare(a.Resource, b.Resource)
+	})
+
+	return requests
+}
+
+func setPolicyCheckErrorState(r *PolicyCheckReconciler, ctx context.Context, cr *operatorv1.PolicyCheck, err error) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+
+	cr.Status.State = typeError
+	cr.Status.Message = err.Error()
+	cr.Status.ObservedGeneration = cr.Generation
+	setSyncedCondition(&cr.Status.Conditions, cr.Generation, err)
+
+	if err := r.Status().Update(ctx, cr); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return resultForError(err)
+}
@@ -0,0 +1,281 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"github.com/minio/madmin-go/v3"
+	iampolicy "github.com/minio/pkg/iam/policy"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+const testDenyDeletePolicyContent = `{
+	"Version": "2012-10-17",
+	"Statement": [
+		{
+			"Effect": "Deny",
+			"Action": ["s3:DeleteObject"],
+			"Resource": ["arn:aws:s3:::*"]
+		}
+	]
+}`
+
+func newTestPolicyCheckReconciler(t *testing.T, objs ...client.Object) (*PolicyCheckReconciler, *fakeMinio) {
+	minio := newFakeMinio()
+	minio.policies["test-policy"] = []byte(testPolicyContent)
+	minio.policies["deny-delete"] = []byte(testDenyDeletePolicyContent)
+	minio.users["test-user"] = &fakeUser{
+		secretKey: "test-secret-key",
+		status:    madmin.AccountEnabled,
+		policies:  []string{"test-policy"},
+	}
+	c := newFakeClient(t, objs...)
+	return &PolicyCheckReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+		Minio:    minio,
+	}, minio
+}
+
+func TestPolicyCheckReconcileRequests(t *testing.T) {
+	cr := &operatorv1.PolicyCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-check", Namespace: "default"},
+		Spec: operatorv1.PolicyCheckSpec{
+			User:     "test-user",
+			Policies: []string{"deny-delete"},
+			Requests: []operatorv1.AccessRequest{
+				{Action: "s3:GetObject", Resource: "arn:aws:s3:::test-bucket/data.csv"},
+				{Action: "s3:PutObject", Resource: "arn:aws:s3:::test-bucket/data.csv"},
+				{Action: "s3:DeleteObject", Resource: "test-bucket/data.csv"},
+			},
+		},
+	}
+	r, minio := newTestPolicyCheckReconciler(t, cr, newTestUser("test-policy"), newTestDenyDeletePolicy())
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s: %s", typeReady, cr.Status.State, cr.Status.Message)
+	}
+	expected := []string{operatorv1.DecisionAllow, operatorv1.DecisionImplicitDeny, operatorv1.DecisionDeny}
+	if len(cr.Status.Results) != len(expected) {
+		t.Fatalf("unexpected results %+v", cr.Status.Results)
+	}
+	for i, result := range cr.Status.Results {
+		if result.Decision != expected[i] {
+			t.Fatalf("expected %s to be %s, got %s", result.Action, expected[i], result.Decision)
+		}
+	}
+
+	// Evaluated again when a policy of the user changes
+	requests := r.checksForPolicy(newTestPolicy())
+	if len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	minio.policies["test-policy"] = []byte(`{"Version":"2012-10-17","Statement":[{"Effect":"Allow","Action":["s3:*"],"Resource":["arn:aws:s3:::test-bucket/*"]}]}`)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.Results[1].Decision != operatorv1.DecisionAllow {
+		t.Fatalf("expected %s to be allowed after the policy changed", cr.Status.Results[1].Action)
+	}
+}
+
+func newTestDenyDeletePolicy() *operatorv1.Policy {
+	policy := newTestPolicy()
+	policy.Name = "deny-delete"
+	policy.Spec.Name = "deny-delete"
+	policy.Spec.Content = testDenyDeletePolicyContent
+	return policy
+}
+
+func TestPolicyCheckReconcileListedPolicies(t *testing.T) {
+	cr := &operatorv1.PolicyCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-check", Namespace: "default"},
+		Spec: operatorv1.PolicyCheckSpec{
+			Policies: []string{"test-policy", "deny-delete"},
+			Requests: []operatorv1.AccessRequest{
+				{Action: "s3:GetObject", Resource: "test-bucket/data.csv"},
+				{Action: "s3:DeleteObject", Resource: "test-bucket/data.csv"},
+			},
+		},
+	}
+	denyDelete := newTestDenyDeletePolicy()
+	denyDelete.Namespace = "tenant"
+	r, _ := newTestPolicyCheckReconciler(t, cr, denyDelete)
+
+	// Policies stored by MinIO but not managed in the namespace are not evaluated
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+
+	// Cluster policies are, with the content of their spec
+	clusterPolicy := newTestClusterPolicy()
+	clusterPolicy.Name = "deny-delete"
+	clusterPolicy.Spec.Name = "deny-delete"
+	clusterPolicy.Spec.Content = `{"Version":"2012-10-17","Statement":[{"Effect":"Deny","Action":["s3:*"],"Resource":["arn:aws:s3:::*"]}]}`
+	if err := r.Create(context.Background(), clusterPolicy); err != nil {
+		t.Fatal(err)
+	}
+	policy := newTestPolicy()
+	if err := r.Create(context.Background(), policy); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s: %s", typeReady, cr.Status.State, cr.Status.Message)
+	}
+	for _, result := range cr.Status.Results {
+		if result.Decision != operatorv1.DecisionDeny {
+			t.Fatalf("expected %s to be denied by the cluster policy, got %s", result.Action, result.Decision)
+		}
+	}
+}
+
+func TestPolicyCheckReconcileGroupPolicies(t *testing.T) {
+	cr := &operatorv1.PolicyCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-check", Namespace: "default"},
+		Spec: operatorv1.PolicyCheckSpec{
+			User:     "test-user",
+			Requests: []operatorv1.AccessRequest{{Action: "s3:DeleteObject", Resource: "test-bucket/data.csv"}},
+		},
+	}
+	r, minio := newTestPolicyCheckReconciler(t, cr, newTestUser("test-policy"))
+	minio.users["test-user"].groups = []string{"auditors"}
+	minio.groups["auditors"] = []string{"deny-delete"}
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if len(cr.Status.Results) != 1 || cr.Status.Results[0].Decision != operatorv1.DecisionDeny {
+		t.Fatalf("expected group policy to deny the request, got %+v", cr.Status.Results)
+	}
+}
+
+func TestPolicyCheckReconcilePolicyChanges(t *testing.T) {
+	policy := newTestPolicy()
+	policy.Spec.Content = `{
+		"Version": "2012-10-17",
+		"Statement": [
+			{
+				"Effect": "Allow",
+				"Action": ["s3:GetObject", "s3:PutObject"],
+				"Resource": ["arn:aws:s3:::test-bucket/*"]
+			}
+		]
+	}`
+	cr := &operatorv1.PolicyCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-check", Namespace: "default"},
+		Spec:       operatorv1.PolicyCheckSpec{Policy: "test-policy"},
+	}
+	r, _ := newTestPolicyCheckReconciler(t, cr, policy, newTestUser("test-policy"))
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s: %s", typeReady, cr.Status.State, cr.Status.Message)
+	}
+	if len(cr.Status.Changes) != 1 {
+		t.Fatalf("unexpected changes %+v", cr.Status.Changes)
+	}
+	change := cr.Status.Changes[0]
+	if change.User != "test-user" || change.Action != "s3:PutObject" ||
+		change.Before != operatorv1.DecisionImplicitDeny || change.After != operatorv1.DecisionAllow {
+		t.Fatalf("unexpected change %+v", change)
+	}
+}
+
+func TestPolicyCheckReconcileMissingUser(t *testing.T) {
+	cr := &operatorv1.PolicyCheck{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-check", Namespace: "default"},
+		Spec: operatorv1.PolicyCheckSpec{
+			User:     "missing",
+			Requests: []operatorv1.AccessRequest{{Action: "s3:GetObject", Resource: "test-bucket/data.csv"}},
+		},
+	}
+	r, _ := newTestPolicyCheckReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, true)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+}
+
+func TestEvaluatePolicies(t *testing.T) {
+	policy := func(content string) iampolicy.Policy {
+		document, err := parsePolicy([]byte(content))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return document
+	}
+	allowAll := policy(`{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::*"}]}`)
+	denyInsecure := policy(`{"Statement":[{"Effect":"Deny","Action":"s3:*","Resource":"arn:aws:s3:::*","Condition":{"Bool":{"aws:SecureTransport":"false"}}}]}`)
+
+	tests := []struct {
+		name       string
+		policies   []iampolicy.Policy
+		conditions map[string][]string
+		request    operatorv1.AccessRequest
+		decision   string
+	}{
+		{
+			name:     "wildcard action",
+			policies: []iampolicy.Policy{policy(`{"Statement":[{"Effect":"Allow","Action":"s3:Get*","Resource":"arn:aws:s3:::a/*"}]}`)},
+			request:  operatorv1.AccessRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::a/b/c"},
+			decision: operatorv1.DecisionAllow,
+		},
+		{
+			name:     "other bucket",
+			policies: []iampolicy.Policy{policy(`{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::a/*"}]}`)},
+			request:  operatorv1.AccessRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::ab/c"},
+			decision: operatorv1.DecisionImplicitDeny,
+		},
+		{
+			name:     "not action",
+			policies: []iampolicy.Policy{policy(`{"Statement":[{"Effect":"Allow","NotAction":"s3:DeleteObject","Resource":"arn:aws:s3:::*"}]}`)},
+			request:  operatorv1.AccessRequest{Action: "s3:DeleteObject", Resource: "arn:aws:s3:::a/b"},
+			decision: operatorv1.DecisionImplicitDeny,
+		},
+		{
+			name:     "username variable",
+			policies: []iampolicy.Policy{policy(`{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::home/${aws:username}/*"}]}`)},
+			request:  operatorv1.AccessRequest{Action: "s3:PutObject", Resource: "arn:aws:s3:::home/test-user/file"},
+			decision: operatorv1.DecisionAllow,
+		},
+		{
+			name:       "condition matching",
+			policies:   []iampolicy.Policy{allowAll, denyInsecure},
+			conditions: map[string][]string{"aws:SecureTransport": {"false"}},
+			request:    operatorv1.AccessRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::a/b"},
+			decision:   operatorv1.DecisionDeny,
+		},
+		{
+			name:       "condition not matching",
+			policies:   []iampolicy.Policy{allowAll, denyInsecure},
+			conditions: map[string][]string{"aws:SecureTransport": {"true"}},
+			request:    operatorv1.AccessRequest{Action: "s3:GetObject", Resource: "arn:aws:s3:::a/b"},
+			decision:   operatorv1.DecisionAllow,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			decision := evaluatePolicies(test.policies, "test-user", test.conditions, test.request)
+			if decision != test.decision {
+				t.Fatalf("expected %s, got %s", test.decision, decision)
+			}
+		})
+	}
+}
@@ -0,0 +1,205 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strings"
+
+	"github.com/minio/pkg/bucket/policy"
+	"github.com/minio/pkg/wildcard"
+	"k8s.io/apimachinery/pkg/api/meta"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// MinioTenantPolicies applying to a namespace
+func tenantPoliciesFor(ctx context.Context, c client.Reader, namespace string) ([]operatorv1.MinioTenantPolicy, error) {
+	policies := &operatorv1.MinioTenantPolicyList{}
+	if err := c.List(ctx, policies); err != nil {
+		return nil, err
+	}
+
+	return slices.DeleteFunc(policies.Items, func(policy operatorv1.MinioTenantPolicy) bool {
+		return !matchesPattern(policy.Spec.Namespaces, namespace)
+	}), nil
+}
+
+// Map a MinioTenantPolicy to the resources of the namespaces it applies to, listed into a new
+// list, so that they are checked again when it changes. Updates map both the old and the new
+// tenant policy, covering the namespaces it stops applying to.
+func resourcesForTenantPolicy(c client.Reader, newList func() client.ObjectList) handler.MapFunc {
+	return func(obj client.Object) []reconcile.Request {
+		namespaces := obj.(*operatorv1.MinioTenantPolicy).Spec.Namespaces
+
+		list := newList()
+		if err := c.List(context.Background(), list); err != nil {
+			return nil
+		}
+		items, err := meta.ExtractList(list)
+		if err != nil {
+			return nil
+		}
+
+		var requests []reconcile.Request
+		for _, item := range items {
+			resource, ok := item.(client.Object)
+			if ok && matchesPattern(namespaces, resource.GetNamespace()) {
+				requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(resource)})
+			}
+		}
+
+		return requests
+	}
+}
+
+// Check a Bucket, Policy or User against the MinioTenantPolicies of its namespace
+func checkTenantPolicies(ctx context.Context, c client.Reader, obj client.Object) error {
+	tenantPolicies, err := tenantPoliciesFor(ctx, c, obj.GetNamespace())
+	if err != nil || len(tenantPolicies) == 0 {
+		return err
+	}
+
+	for _, tenantPolicy := range tenantPolicies {
+		var reason string
+		switch cr := obj.(type) {
+		case *operatorv1.Bucket:
+			reason = bucketViolation(tenantPolicy.Spec, cr)
+		case *operatorv1.Policy:
+			content, err := renderPolicy(ctx, c, cr)
+			if err != nil {
+				return err
+			}
+			if reason, err = policyViolation(tenantPolicy.Spec, content); err != nil {
+				return err
+			}
+		case *operatorv1.User:
+			if reason, err = userViolation(ctx, c, cr); err != nil {
+				return err
+			}
+		}
+
+		if reason != "" {
+			return fmt.Errorf("%w %s: %s", errTenantPolicyViolation, tenantPolicy.Name, reason)
+		}
+	}
+
+	return nil
+}
+
+func bucketViolation(spec operatorv1.MinioTenantPolicySpec, cr *operatorv1.Bucket) string {
+	if len(spec.BucketNames) > 0 {
+		if !matchesPattern(spec.BucketNames, cr.Spec.Name) {
+			return fmt.Sprintf("bucket %s is not allowed", cr.Spec.Name)
+		}
+
+		// Archives on other instances are not restricted
+		if cr.Spec.OnDelete != nil && cr.Spec.OnDelete.ArchiveTo != nil && cr.Spec.OnDelete.ArchiveTo.Endpoint == "" {
+			if archive := cr.Spec.OnDelete.ArchiveTo.Bucket; !matchesPattern(spec.BucketNames, archive) {
+				return fmt.Sprintf("archive bucket %s is not allowed", archive)
+			}
+		}
+	}
+
+	if spec.MaxQuota != nil {
+		if cr.Spec.Quota == nil {
+			return fmt.Sprintf("buckets need a quota of at most %s", spec.MaxQuota.String())
+		}
+		if cr.Spec.Quota.Cmp(*spec.MaxQuota) > 0 {
+			return fmt.Sprintf("quota %s exceeds %s", cr.Spec.Quota.String(), spec.MaxQuota.String())
+		}
+	}
+
+	return ""
+}
+
+// Check the actions and resources allowed by a policy. Patterns are matched as plain strings,
+// so e.g. s3:* is only allowed by a * pattern, even if the actions it stands for are allowed.
+func policyViolation(spec operatorv1.MinioTenantPolicySpec, content []byte) (string, error) {
+	document, err := parsePolicy(content)
+	if err != nil {
+		return "", err
+	}
+
+	for _, statement := range document.Statements {
+		if statement.Effect != policy.Allow {
+			continue
+		}
+
+		if len(spec.Actions) > 0 {
+			if !statement.NotActions.IsEmpty() {
+				return "statements allowing NotAction are not allowed", nil
+			}
+			for action := range statement.Actions {
+				if !matchesPattern(spec.Actions, string(action)) {
+					return fmt.Sprintf("action %s is not allowed", action), nil
+				}
+			}
+		}
+
+		if len(spec.BucketNames) > 0 {
+			for resource := range statement.Resources {
+				bucket, _, _ := strings.Cut(resource.Pattern, "/")
+				if !matchesPattern(spec.BucketNames, bucket) {
+					return fmt.Sprintf("resource %s is not allowed", resource), nil
+				}
+			}
+		}
+	}
+
+	return "", nil
+}
+
+// Check whether any pattern matches value, where * matches any sequence of characters and ? any single one
+func matchesPattern(patterns []string, value string) bool {
+	return slices.ContainsFunc(patterns, func(pattern string) bool {
+		return wildcard.Match(pattern, value)
+	})
+}
+
+// Users may only hold policies of their namespace or cluster policies, not the ones of other
+// namespaces nor the ones built into MinIO
+func userViolation(ctx context.Context, c client.Reader, cr *operatorv1.User) (string, error) {
+	if len(cr.Spec.Policies) == 0 {
+		return "", nil
+	}
+
+	policies := &operatorv1.PolicyList{}
+	if err := c.List(ctx, policies, client.InNamespace(cr.Namespace)); err != nil {
+		return "", err
+	}
+
+	for _, name := range cr.Spec.Policies {
+		i := slices.IndexFunc(policies.Items, func(policy operatorv1.Policy) bool {
+			return policy.Spec.Name == name
+		})
+		if i >= 0 {
+			// The policy in MinIO is the one of the namespace owning the name
+			owner, err := nameOwnedElsewhere(ctx, c, &policies.Items[i])
+			if err != nil {
+				return "", err
+			}
+			if owner != "" {
+				return fmt.Sprintf("policy %s is owned by namespace %s", name, owner), nil
+			}
+			continue
+		}
+
+		found, err := clusterPolicyExists(ctx, c, name)
+		if err != nil {
+			return "", err
+		}
+		if !found {
+			return fmt.Sprintf("policy %s is neither a Policy in namespace %s nor a ClusterPolicy", name, cr.Namespace), nil
+		}
+	}
+
+	return "", nil
+}
@@ -0,0 +1,207 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/api/meta"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func newTestTenantPolicy() *operatorv1.MinioTenantPolicy {
+	maxQuota := resource.MustParse("10Gi")
+	return &operatorv1.MinioTenantPolicy{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-tenant"},
+		Spec: operatorv1.MinioTenantPolicySpec{
+			Namespaces:  []string{"default"},
+			BucketNames: []string{"test-*"},
+			Actions:     []string{"s3:Get*", "s3:List*"},
+			MaxQuota:    &maxQuota,
+		},
+	}
+}
+
+func TestPolicyViolation(t *testing.T) {
+	spec := newTestTenantPolicy().Spec
+
+	tests := []struct {
+		name     string
+		content  string
+		violates bool
+	}{
+		{
+			name:    "allowed",
+			content: testPolicyContent,
+		},
+		{
+			name:     "any bucket",
+			content:  `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::*"}]}`,
+			violates: true,
+		},
+		{
+			name:     "other bucket",
+			content:  `{"Statement":[{"Effect":"Allow","Action":"s3:GetObject","Resource":"arn:aws:s3:::other-bucket/*"}]}`,
+			violates: true,
+		},
+		{
+			name:     "action",
+			content:  `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::test-bucket/*"}]}`,
+			violates: true,
+		},
+		{
+			name:     "not action",
+			content:  `{"Statement":[{"Effect":"Allow","NotAction":"s3:PutObject","Resource":"arn:aws:s3:::test-bucket/*"}]}`,
+			violates: true,
+		},
+		{
+			name:    "deny",
+			content: `{"Statement":[{"Effect":"Deny","Action":"s3:*","Resource":"arn:aws:s3:::*"}]}`,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason, err := policyViolation(spec, []byte(test.content))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if (reason != "") != test.violates {
+				t.Fatalf("expected violation %v, got %q", test.violates, reason)
+			}
+		})
+	}
+}
+
+func TestBucketViolation(t *testing.T) {
+	spec := newTestTenantPolicy().Spec
+
+	tests := []struct {
+		name     string
+		bucket   *operatorv1.Bucket
+		violates bool
+	}{
+		{name: "allowed", bucket: newTestBucket("1Gi")},
+		{name: "without quota", bucket: newTestBucket(""), violates: true},
+		{name: "quota", bucket: newTestBucket("20Gi"), violates: true},
+		{
+			name: "name",
+			bucket: func() *operatorv1.Bucket {
+				bucket := newTestBucket("1Gi")
+				bucket.Spec.Name = "other-bucket"
+				return bucket
+			}(),
+			violates: true,
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			reason := bucketViolation(spec, test.bucket)
+			if (reason != "") != test.violates {
+				t.Fatalf("expected violation %v, got %q", test.violates, reason)
+			}
+		})
+	}
+}
+
+func TestBucketReconcileTenantPolicy(t *testing.T) {
+	cr := newTestBucket("")
+	tenantPolicy := newTestTenantPolicy()
+	r, minio := newTestBucketReconciler(t, cr, tenantPolicy)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError || !meta.IsStatusConditionFalse(cr.Status.Conditions, conditionSynced) {
+		t.Fatalf("unexpected status %+v", cr.Status)
+	}
+	if _, found := minio.buckets["test-bucket"]; found {
+		t.Fatal("expected bucket not to be created")
+	}
+
+	// Checked again when a tenant policy of the namespace changes
+	mapper := resourcesForTenantPolicy(r.Client, func() client.ObjectList { return &operatorv1.BucketList{} })
+	if requests := mapper(tenantPolicy); len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+
+	// Other namespaces are not restricted
+	tenantPolicy.Spec.Namespaces = []string{"team-*"}
+	if err := r.Update(context.Background(), tenantPolicy); err != nil {
+		t.Fatal(err)
+	}
+	if requests := mapper(tenantPolicy); len(requests) != 0 {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+	if err := checkTenantPolicies(context.Background(), r.Client, cr); err != nil {
+		t.Fatalf("expected bucket to be allowed, got %v", err)
+	}
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.buckets["test-bucket"]; !found {
+		t.Fatal("expected bucket to be created once the tenant policy was relaxed")
+	}
+}
+
+func TestUserReconcileTenantPolicy(t *testing.T) {
+	other := newTestPolicy()
+	other.Namespace = "other"
+	cr := newTestUser("test-policy")
+	r, minio := newTestUserReconciler(t, cr, newTestTenantPolicy(), other)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected policy of another namespace to be rejected, got %+v", cr.Status)
+	}
+	if _, found := minio.users["test-user"]; found {
+		t.Fatal("expected user not to be created")
+	}
+
+	// Policies of the same namespace and cluster policies are allowed
+	for _, obj := range []client.Object{newTestPolicy(), newTestClusterPolicy()} {
+		if err := r.Create(context.Background(), obj); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cr.Spec.Policies = []string{"test-policy"}
+	if err := checkTenantPolicies(context.Background(), r.Client, cr); err != nil {
+		t.Fatalf("expected user to be allowed, got %v", err)
+	}
+}
+
+func TestTenantPolicyValidator(t *testing.T) {
+	c := newFakeClient(t, newTestTenantPolicy())
+	v := &TenantPolicyValidator{Client: c}
+
+	policy := newTestPolicy()
+	policy.Spec.Content = `{"Statement":[{"Effect":"Allow","Action":"s3:*","Resource":"arn:aws:s3:::*"}]}`
+	err := v.ValidateCreate(context.Background(), policy)
+	if !errors.Is(err, errTenantPolicyViolation) {
+		t.Fatalf("expected policy to be rejected, got %v", err)
+	}
+
+	// Finalizers of resources being deleted can be removed
+	now := metav1.Now()
+	policy.DeletionTimestamp = &now
+	if err := v.ValidateUpdate(context.Background(), policy, policy); err != nil {
+		t.Fatalf("expected update to be allowed, got %v", err)
+	}
+
+	// Deletion protection of buckets is still enforced
+	bucket := newTestBucket("1Gi")
+	bucket.Spec.DeletionProtection = true
+	if err := v.ValidateDelete(context.Background(), bucket); err == nil {
+		t.Fatal("expected deletion of protected bucket to be rejected")
+	}
+}
@@ -0,0 +1,74 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"errors"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+//+kubebuilder:webhook:path=/validate-minio-scc-digitalhub-github-io-v1-policy,mutating=false,failurePolicy=fail,sideEffects=None,groups=minio.scc-digitalhub.github.io,resources=policies,verbs=create;update,versions=v1,name=vpolicy.kb.io,admissionReviewVersions=v1
+//+kubebuilder:webhook:path=/validate-minio-scc-digitalhub-github-io-v1-user,mutating=false,failurePolicy=fail,sideEffects=None,groups=minio.scc-digitalhub.github.io,resources=users,verbs=create;update,versions=v1,name=vuser.kb.io,admissionReviewVersions=v1
+
+// TenantPolicyValidator rejects Buckets, Policies and Users violating the MinioTenantPolicies
+// of their namespace, besides running the validations of the resources themselves
+type TenantPolicyValidator struct {
+	Client client.Reader
+}
+
+var _ admission.CustomValidator = &TenantPolicyValidator{}
+
+// SetupWebhookWithManager registers the validating webhooks of Bucket, Policy and User
+func (v *TenantPolicyValidator) SetupWebhookWithManager(mgr ctrl.Manager) error {
+	for _, obj := range []runtime.Object{&operatorv1.Bucket{}, &operatorv1.Policy{}, &operatorv1.User{}} {
+		if err := ctrl.NewWebhookManagedBy(mgr).For(obj).WithValidator(v).Complete(); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// ValidateCreate implements admission.CustomValidator
+func (v *TenantPolicyValidator) ValidateCreate(ctx context.Context, obj runtime.Object) error {
+	return v.validate(ctx, obj)
+}
+
+// ValidateUpdate implements admission.CustomValidator
+func (v *TenantPolicyValidator) ValidateUpdate(ctx context.Context, oldObj, newObj runtime.Object) error {
+	return v.validate(ctx, newObj)
+}
+
+// ValidateDelete implements admission.CustomValidator
+func (v *TenantPolicyValidator) ValidateDelete(ctx context.Context, obj runtime.Object) error {
+	if bucket, ok := obj.(*operatorv1.Bucket); ok {
+		return bucket.ValidateDelete()
+	}
+
+	return nil
+}
+
+func (v *TenantPolicyValidator) validate(ctx context.Context, obj runtime.Object) error {
+	cr, ok := obj.(client.Object)
+	// Resources being deleted must be able to drop their finalizers
+	if !ok || cr.GetDeletionTimestamp() != nil {
+		return nil
+	}
+
+	err := checkTenantPolicies(ctx, v.Client, cr)
+	if errors.Is(err, errTenantPolicyViolation) {
+		return err
+	}
+
+	// Other errors, e.g. missing policy fragments, are reported by the reconcilers
+	return nil
+}
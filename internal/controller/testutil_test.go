@@ -0,0 +1,61 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"testing"
+
+	"k8s.io/apimachinery/pkg/runtime"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Build a fake Kubernetes client holding the given operator resources
+func newFakeClient(t *testing.T, objs ...client.Object) client.Client {
+	t.Helper()
+
+	scheme := runtime.NewScheme()
+	if err := clientgoscheme.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+	if err := operatorv1.AddToScheme(scheme); err != nil {
+		t.Fatal(err)
+	}
+
+	return fake.NewClientBuilder().
+		WithScheme(scheme).
+		WithObjects(objs...).
+		WithIndex(&operatorv1.User{}, userPolicyIndex, indexUserPolicies).
+		WithIndex(&operatorv1.Bucket{}, minioNameIndex, indexBucketName).
+		WithIndex(&operatorv1.Policy{}, minioNameIndex, indexPolicyName).
+		WithIndex(&operatorv1.ClusterPolicy{}, minioNameIndex, indexClusterPolicyName).
+		Build()
+}
+
+// Run reconcile once for obj, failing the test on unexpected errors
+func reconcileOnce(t *testing.T, r reconcile.Reconciler, obj client.Object, wantErr bool) ctrl.Result {
+	t.Helper()
+
+	result, err := r.Reconcile(context.Background(), ctrl.Request{NamespacedName: client.ObjectKeyFromObject(obj)})
+	if (err != nil) != wantErr {
+		t.Fatalf("unexpected reconcile error: %v", err)
+	}
+	return result
+}
+
+// Fetch the current state of obj, failing the test if it cannot be read
+func refetch(t *testing.T, c client.Client, obj client.Object) {
+	t.Helper()
+
+	if err := c.Get(context.Background(), client.ObjectKeyFromObject(obj), obj); err != nil {
+		t.Fatalf("failed to get %s: %v", obj.GetName(), err)
+	}
+}
@@ -6,17 +6,28 @@ package controller
 
 import (
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
 	"fmt"
 	"slices"
 	"strings"
+	"time"
 
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
 	apierrors "k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
 	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 
 	"github.com/minio/madmin-go/v3"
 	miniov1 "github.com/scc-digitalhub/minio-operator/api/v1"
@@ -30,11 +41,18 @@ type UserReconciler struct {
 	client.Client
 	Scheme   *runtime.Scheme
 	Recorder record.EventRecorder
+	Minio    MinioAPI
 }
 
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=users,verbs=get;list;watch;create;update;patch;delete
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=users/status,verbs=get;update;patch
 //+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=users/finalizers,verbs=update
+//+kubebuilder:rbac:groups=core,resources=secrets,verbs=get;list;watch;create;update
+//+kubebuilder:rbac:groups=apps,resources=deployments,verbs=get;list;watch;patch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=miniotenantpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=policies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=minio.scc-digitalhub.github.io,resources=clusterpolicies,verbs=get;list;watch
+//+kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch
 
 func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
@@ -52,6 +70,25 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{}, err
 	}
 
+	// Paused resources are only checked for drift, without writing to MinIO
+	paused, err := pausedReason(ctx, r.Client, cr)
+	if err != nil {
+		log.Error(err, "Failed to check if reconciliation is paused")
+		return ctrl.Result{}, err
+	}
+	if paused != "" {
+		return r.reportDrift(ctx, cr, paused)
+	}
+	if clearPausedConditions(&cr.Status.Conditions) {
+		log.Info("Resuming reconciliation")
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
+	}
+
 	// If status is unknown, set Creating
 	if cr.Status.State == "" {
 		log.Info("State unspecified, updating to creating")
@@ -64,18 +101,35 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		return ctrl.Result{Requeue: true}, nil
 	}
 
+	// Resources violating the tenant policies of their namespace are not written to MinIO
+	if cr.GetDeletionTimestamp() == nil && cr.Status.State != typeError {
+		if err := checkTenantPolicies(ctx, r.Client, cr); err != nil {
+			log.Error(err, "Failed to check tenant policies")
+			return setUserErrorState(r, ctx, cr, err)
+		}
+	}
+
+	// Generated secret keys are written to their Secret before being set in MinIO
+	if cr.GetDeletionTimestamp() == nil && cr.Spec.Rotation != nil &&
+		(cr.Status.State == typeCreating || cr.Status.State == typeReady) {
+		if err := r.reconcileRotation(ctx, cr); err != nil {
+			log.Error(err, "Failed to rotate secret key")
+			return setUserErrorState(r, ctx, cr, err)
+		}
+	}
+
 	// Create resource, if it doesn't exist
 	if cr.Status.State == typeCreating {
 		log.Info("Creating resource")
 
-		adminClient, err := getAdminClient()
+		secretKey, err := r.secretKey(ctx, cr)
 		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
+			log.Error(err, "Failed to get secret key")
 			return setUserErrorState(r, ctx, cr, err)
 		}
 
 		// Does not return error if user already exists
-		err = adminClient.SetUser(context.Background(), cr.Spec.AccessKey, cr.Spec.SecretKey, madmin.AccountStatus(cr.Spec.AccountStatus))
+		err = r.Minio.SetUser(ctx, cr.Spec.AccessKey, secretKey, madmin.AccountStatus(accountStatus(cr)))
 		if err != nil {
 			log.Error(err, "Error while creating user")
 			return setUserErrorState(r, ctx, cr, err)
@@ -95,15 +149,15 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 			}
 		}
 
-		// Set policies
-		if cr.Spec.AccountStatus == "enabled" && len(cr.Spec.Policies) > 0 {
+		// Set policies, also on disabled accounts so that they are in place once enabled
+		if len(cr.Spec.Policies) > 0 {
 			req := madmin.PolicyAssociationReq{
 				Policies: cr.Spec.Policies,
 				User:     cr.Spec.AccessKey,
 			}
 
-			_, err := adminClient.AttachPolicy(context.Background(), req)
-			if err != nil && !strings.Contains(err.Error(), "policy update has no net effect") {
+			err := r.Minio.AttachPolicy(ctx, req)
+			if err != nil && !isPolicyChangeAlreadyApplied(err) {
 				log.Error(err, "Error while assigning policies to user")
 				return setUserErrorState(r, ctx, cr, err)
 			}
@@ -115,6 +169,10 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 		}
 
 		cr.Status.State = typeReady
+		cr.Status.Message = ""
+		cr.Status.CredentialsHash = credentialsHash(cr, secretKey)
+		cr.Status.RemainingLifetime = remainingLifetime(cr)
+		setSyncedCondition(&cr.Status.Conditions, cr.Generation, nil)
 		if err = r.Status().Update(ctx, cr); err != nil {
 			log.Error(err, genericStatusUpdateFailedMessage)
 			return ctrl.Result{}, err
@@ -133,7 +191,7 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 
 			// Perform all operations required before removing the finalizer to allow
 			// the Kubernetes API to remove the custom resource.
-			if err := r.finalizerOpsForUser(cr); err != nil {
+			if err := trackFinalizer("User", func() error { return r.finalizerOpsForUser(ctx, cr) }); err != nil {
 				log.Error(err, "Finalizer operations failed")
 				return ctrl.Result{Requeue: true}, nil
 			}
@@ -162,58 +220,154 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 	// Check if resource needs updating
 	if cr.Status.State == typeReady {
 		log.Info("Resource in Ready state")
-		adminClient, err := getAdminClient()
-		if err != nil {
-			log.Error(err, failedToObtainAdminClientMessage)
-			return setUserErrorState(r, ctx, cr, err)
-		}
 
-		// We are unable to check if the secret key has changed, so we just set it again
-		err = adminClient.SetUser(context.Background(), cr.Spec.AccessKey, cr.Spec.SecretKey, madmin.AccountStatus(cr.Spec.AccountStatus))
+		secretKey, err := r.secretKey(ctx, cr)
 		if err != nil {
-			log.Error(err, "Error setting user")
+			log.Error(err, "Failed to get secret key")
 			return setUserErrorState(r, ctx, cr, err)
 		}
 
-		// Check policies
-		userInfo, err := adminClient.GetUserInfo(context.Background(), cr.Spec.AccessKey)
-		if err != nil {
+		userInfo, err := r.Minio.GetUserInfo(ctx, cr.Spec.AccessKey)
+		if err != nil && !isNoSuchUser(err) {
 			log.Error(err, "Unable to retrieve user info")
 			return setUserErrorState(r, ctx, cr, err)
 		}
 
+		// Expired users are disabled, and deleted after a further grace period when requested
+		setUserExpirationMetric(cr)
+		if lifetime := remainingLifetime(cr); lifetime != cr.Status.RemainingLifetime {
+			cr.Status.RemainingLifetime = lifetime
+			if err := r.Status().Update(ctx, cr); err != nil {
+				log.Error(err, genericStatusUpdateFailedMessage)
+				return ctrl.Result{}, err
+			}
+		}
+		if deleteAt, found := userDeletionTime(cr); found && !time.Now().Before(deleteAt) {
+			log.Info("Deleting expired user")
+			r.Recorder.Event(cr, "Warning", "Expired",
+				fmt.Sprintf("User expired at %s and is being deleted", cr.Spec.ExpiresAt.UTC().Format(time.RFC3339)))
+			if err := r.Delete(ctx, cr); err != nil && !apierrors.IsNotFound(err) {
+				log.Error(err, "Failed to delete expired user")
+				return ctrl.Result{}, err
+			}
+			return ctrl.Result{}, nil
+		}
+		if isUserExpired(cr) && err == nil && userInfo.Status == madmin.AccountEnabled {
+			log.Info("Disabling expired user")
+			if err := r.Minio.SetUserStatus(ctx, cr.Spec.AccessKey, madmin.AccountDisabled); err != nil {
+				log.Error(err, "Error disabling expired user")
+				return setUserErrorState(r, ctx, cr, err)
+			}
+			userInfo.Status = madmin.AccountDisabled
+			r.Recorder.Event(cr, "Warning", "Expired",
+				fmt.Sprintf("User expired at %s and was disabled", cr.Spec.ExpiresAt.UTC().Format(time.RFC3339)))
+		}
+
+		// Credentials are only set again when they change, or MinIO no longer matches them
+		hash := credentialsHash(cr, secretKey)
+		reason := ""
+		switch {
+		case isNoSuchUser(err):
+			reason = "user does not exist"
+		case hash != cr.Status.CredentialsHash:
+			// Rotated secret keys wait for the Deployments using them to roll out
+			pending, err := r.rolloutPending(ctx, cr)
+			if err != nil {
+				log.Error(err, "Failed to check deployment rollouts")
+				return setUserErrorState(r, ctx, cr, err)
+			}
+			if pending {
+				log.Info("Waiting for deployments to roll out before setting the rotated secret key")
+				return ctrl.Result{RequeueAfter: time.Until(rotationGraceEnd(cr))}, nil
+			}
+			reason = "credentials changed"
+		case userInfo.Status == madmin.AccountEnabled && accountStatus(cr) == "enabled":
+			err := r.Minio.VerifyCredentials(ctx, cr.Spec.AccessKey, secretKey)
+			if errors.Is(err, errInvalidCredentials) {
+				reason = "credentials rejected"
+			} else if err != nil {
+				log.Error(err, "Failed to verify credentials")
+			}
+		}
+
+		if reason != "" {
+			log.Info("Setting user credentials", "reason", reason)
+			if hash == cr.Status.CredentialsHash {
+				recordDriftCorrection("User")
+			}
+
+			err = r.Minio.SetUser(ctx, cr.Spec.AccessKey, secretKey, madmin.AccountStatus(accountStatus(cr)))
+			if err != nil {
+				log.Error(err, "Error setting user")
+				return setUserErrorState(r, ctx, cr, err)
+			}
+			userInfo.Status = madmin.AccountStatus(accountStatus(cr))
+
+			cr.Status.CredentialsHash = hash
+			if err = r.Status().Update(ctx, cr); err != nil {
+				log.Error(err, genericStatusUpdateFailedMessage)
+				return ctrl.Result{}, err
+			}
+		}
+
+		// The account status is toggled on its own, keeping the credentials
+		if string(userInfo.Status) != accountStatus(cr) {
+			log.Info("Setting account status", "status", accountStatus(cr))
+			err := r.Minio.SetUserStatus(ctx, cr.Spec.AccessKey, madmin.AccountStatus(accountStatus(cr)))
+			if err != nil {
+				log.Error(err, "Error setting account status")
+				return setUserErrorState(r, ctx, cr, err)
+			}
+		}
+
+		// Policies are kept in line with the spec whether the account is enabled or not
 		currentPolicies := strings.Split(userInfo.PolicyName, ",")
 		toDetach, toAttach := arrayDifference(cr.Spec.Policies, currentPolicies)
-		if cr.Spec.AccountStatus == "enabled" && len(toDetach) > 0 {
+		if len(toDetach) > 0 || len(toAttach) > 0 {
+			recordDriftCorrection("User")
+		}
+		if len(toDetach) > 0 {
 			req := madmin.PolicyAssociationReq{
 				Policies: toDetach,
 				User:     cr.Spec.AccessKey,
 			}
-			_, err := adminClient.DetachPolicy(context.Background(), req)
+			err := r.Minio.DetachPolicy(ctx, req)
 			if err != nil {
 				log.Error(err, "Error detaching policies")
 				return setUserErrorState(r, ctx, cr, err)
 			}
 		}
-		if cr.Spec.AccountStatus == "enabled" && len(toAttach) > 0 {
+		if len(toAttach) > 0 {
 			req := madmin.PolicyAssociationReq{
 				Policies: toAttach,
 				User:     cr.Spec.AccessKey,
 			}
-			_, err := adminClient.AttachPolicy(context.Background(), req)
+			err := r.Minio.AttachPolicy(ctx, req)
 			if err != nil {
 				log.Error(err, "Error attaching policies")
 				return setUserErrorState(r, ctx, cr, err)
 			}
 		}
 
-		return ctrl.Result{}, nil
+		return ctrl.Result{RequeueAfter: minRequeueAfter(rotationRequeueAfter(cr), expiryRequeueAfter(cr))}, nil
 	}
 
 	// Error state
 	if cr.Status.State == typeError {
 		log.Info("Resource in error state")
-		return ctrl.Result{}, nil
+
+		// Permanent errors are retried once the spec changes, any other on requeue
+		if !shouldRetryError(cr.Status.Conditions, cr.Generation) {
+			return ctrl.Result{}, nil
+		}
+
+		cr.Status.State = typeCreating
+		if err = r.Status().Update(ctx, cr); err != nil {
+			log.Error(err, genericStatusUpdateFailedMessage)
+			return ctrl.Result{}, err
+		}
+
+		return ctrl.Result{Requeue: true}, nil
 	}
 
 	return ctrl.Result{}, nil
@@ -223,22 +377,94 @@ func (r *UserReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.
 func (r *UserReconciler) SetupWithManager(mgr ctrl.Manager) error {
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&miniov1.User{}).
+		Watches(&source.Kind{Type: &corev1.Secret{}}, handler.EnqueueRequestsFromMapFunc(r.usersForSecret)).
+		Watches(&source.Kind{Type: &appsv1.Deployment{}}, handler.EnqueueRequestsFromMapFunc(r.usersForDeployment)).
+		Watches(&source.Kind{Type: &miniov1.MinioTenantPolicy{}}, handler.EnqueueRequestsFromMapFunc(
+			resourcesForTenantPolicy(r.Client, func() client.ObjectList { return &miniov1.UserList{} }))).
 		Complete(r)
 }
 
-// Perform required operations before deleting the CR
-func (r *UserReconciler) finalizerOpsForUser(cr *operatorv1.User) error {
-	adminClient, err := getAdminClient()
-	if err != nil {
-		return err
+// Users referencing a Secret for their secret key or rotating it, reconciled when it changes
+func (r *UserReconciler) usersForSecret(secret client.Object) []reconcile.Request {
+	users := &operatorv1.UserList{}
+	if err := r.List(context.Background(), users, client.InNamespace(secret.GetNamespace())); err != nil {
+		return nil
 	}
 
-	err = adminClient.RemoveUser(context.Background(), cr.Spec.AccessKey)
-	if err != nil {
-		if !strings.Contains(err.Error(), "does not exist") {
-			return err
+	var requests []reconcile.Request
+	for _, user := range users.Items {
+		referenced := user.Spec.SecretKeyRef != nil && user.Spec.SecretKeyRef.Name == secret.GetName()
+		rotated := user.Spec.Rotation != nil && rotationSecretName(&user) == secret.GetName()
+		if referenced || rotated {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&user)})
+		}
+	}
+
+	return requests
+}
+
+// Rotated users whose credentials Secret is used by a Deployment, reconciled as it rolls out
+func (r *UserReconciler) usersForDeployment(obj client.Object) []reconcile.Request {
+	deployment, ok := obj.(*appsv1.Deployment)
+	if !ok {
+		return nil
+	}
+
+	users := &operatorv1.UserList{}
+	if err := r.List(context.Background(), users, client.InNamespace(deployment.Namespace)); err != nil {
+		return nil
+	}
+
+	var requests []reconcile.Request
+	for _, user := range users.Items {
+		if user.Spec.Rotation != nil && podUsesSecret(&deployment.Spec.Template.Spec, rotationSecretName(&user)) {
+			requests = append(requests, reconcile.Request{NamespacedName: client.ObjectKeyFromObject(&user)})
+		}
+	}
+
+	return requests
+}
+
+// Return the secret key of a user, read from its Secret when referenced or rotated
+func (r *UserReconciler) secretKey(ctx context.Context, cr *operatorv1.User) (string, error) {
+	if cr.Spec.Rotation != nil {
+		return r.rotatedSecretKey(ctx, cr)
+	}
+
+	ref := cr.Spec.SecretKeyRef
+	if ref == nil {
+		if cr.Spec.SecretKey == "" {
+			return "", errors.New("either secretKey, secretKeyRef or rotation is required")
 		}
+		return cr.Spec.SecretKey, nil
+	}
+
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: ref.Name, Namespace: cr.Namespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", ref.Name, err)
 	}
+	secretKey := string(secret.Data[ref.Key])
+	if secretKey == "" {
+		return "", fmt.Errorf("secret %s has no %s key", ref.Name, ref.Key)
+	}
+
+	return secretKey, nil
+}
+
+// Hash of a user's credentials, keyed by the resource UID so that it cannot be compared across resources
+func credentialsHash(cr *operatorv1.User, secretKey string) string {
+	mac := hmac.New(sha256.New, []byte(cr.UID))
+	mac.Write([]byte(cr.Spec.AccessKey + "\n" + secretKey))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Perform required operations before deleting the CR
+func (r *UserReconciler) finalizerOpsForUser(ctx context.Context, cr *operatorv1.User) error {
+	err := r.Minio.RemoveUser(ctx, cr.Spec.AccessKey)
+	if err != nil && !isNoSuchUser(err) {
+		return err
+	}
+	deleteUserExpirationMetric(cr)
 
 	// The following implementation will raise an event
 	r.Recorder.Event(cr, "Warning", "Deleting",
@@ -249,18 +475,64 @@ func (r *UserReconciler) finalizerOpsForUser(cr *operatorv1.User) error {
 	return nil
 }
 
+// Report how the User in MinIO differs from the spec, without changing it
+func (r *UserReconciler) reportDrift(ctx context.Context, cr *operatorv1.User, reason string) (ctrl.Result, error) {
+	log := log.FromContext(ctx)
+	log.Info("Reconciliation paused, checking drift", "reason", reason)
+
+	drift, err := r.detectDrift(ctx, cr)
+	if err != nil {
+		log.Error(err, "Failed to check drift")
+	}
+
+	setPausedConditions(&cr.Status.Conditions, cr.Generation, reason, drift, err)
+	if err := r.Status().Update(ctx, cr); err != nil {
+		log.Error(err, genericStatusUpdateFailedMessage)
+		return ctrl.Result{}, err
+	}
+
+	return ctrl.Result{RequeueAfter: pausedDriftCheckInterval}, nil
+}
+
+// Return the differences between the user in MinIO and the spec. The secret key cannot be checked.
+func (r *UserReconciler) detectDrift(ctx context.Context, cr *operatorv1.User) ([]string, error) {
+	userInfo, err := r.Minio.GetUserInfo(ctx, cr.Spec.AccessKey)
+	if isNoSuchUser(err) {
+		return []string{"user does not exist"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var drift []string
+	if string(userInfo.Status) != accountStatus(cr) {
+		drift = append(drift, fmt.Sprintf("account status is %s, expected %s", userInfo.Status, accountStatus(cr)))
+	}
+
+	extra, missing := arrayDifference(cr.Spec.Policies, strings.Split(userInfo.PolicyName, ","))
+	if len(extra) > 0 {
+		drift = append(drift, fmt.Sprintf("policies %s are attached but not in the spec", strings.Join(extra, ",")))
+	}
+	if len(missing) > 0 {
+		drift = append(drift, fmt.Sprintf("policies %s are not attached", strings.Join(missing, ",")))
+	}
+
+	return drift, nil
+}
+
 func setUserErrorState(r *UserReconciler, ctx context.Context, cr *operatorv1.User, err error) (ctrl.Result, error) {
 	log := log.FromContext(ctx)
 
 	cr.Status.State = typeError
 	cr.Status.Message = err.Error()
+	setSyncedCondition(&cr.Status.Conditions, cr.Generation, err)
 
 	if err := r.Status().Update(ctx, cr); err != nil {
 		log.Error(err, genericStatusUpdateFailedMessage)
 		return ctrl.Result{}, err
 	}
 
-	return ctrl.Result{}, err
+	return resultForError(err)
 }
 
 func arrayDifference(a []string, b []string) ([]string, []string) {
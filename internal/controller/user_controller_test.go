@@ -0,0 +1,569 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/minio/madmin-go/v3"
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/api/meta"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+func newTestUser(policies ...string) *operatorv1.User {
+	return &operatorv1.User{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-user", Namespace: "default"},
+		Spec: operatorv1.UserSpec{
+			AccessKey:     "test-user",
+			SecretKey:     "test-secret-key",
+			Policies:      policies,
+			AccountStatus: "enabled",
+		},
+	}
+}
+
+func newTestUserReconciler(t *testing.T, cr *operatorv1.User, objs ...client.Object) (*UserReconciler, *fakeMinio) {
+	minio := newFakeMinio()
+	minio.policies["readonly"] = []byte(`{}`)
+	minio.policies["writeonly"] = []byte(`{}`)
+	c := newFakeClient(t, append(objs, cr)...)
+	return &UserReconciler{
+		Client:   c,
+		Scheme:   c.Scheme(),
+		Recorder: record.NewFakeRecorder(10),
+		Minio:    minio,
+	}, minio
+}
+
+func TestUserReconcileCreate(t *testing.T) {
+	cr := newTestUser("readonly")
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeReady {
+		t.Fatalf("expected state %s, got %s", typeReady, cr.Status.State)
+	}
+	if !controllerutil.ContainsFinalizer(cr, userFinalizer) {
+		t.Fatal("expected finalizer to be added")
+	}
+
+	user, found := minio.users["test-user"]
+	if !found {
+		t.Fatal("expected user to be created")
+	}
+	if user.secretKey != "test-secret-key" || user.status != madmin.AccountEnabled {
+		t.Fatalf("unexpected user %+v", user)
+	}
+	if !slices.Equal(user.policies, []string{"readonly"}) {
+		t.Fatalf("unexpected policies %v", user.policies)
+	}
+}
+
+func TestUserReconcileCreateError(t *testing.T) {
+	cr := newTestUser("missing")
+	cr.Status.State = typeCreating
+	r, _ := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if cr.Status.State != typeError {
+		t.Fatalf("expected state %s, got %s", typeError, cr.Status.State)
+	}
+	if cr.Status.Message != errNoSuchPolicy().Error() {
+		t.Fatalf("unexpected message %q", cr.Status.Message)
+	}
+}
+
+func TestUserReconcilePolicyDrift(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// Policies changed out of band
+	minio.users["test-user"].policies = []string{"writeonly"}
+
+	reconcileOnce(t, r, cr, false)
+	if !slices.Equal(minio.users["test-user"].policies, []string{"readonly"}) {
+		t.Fatalf("expected policies to be restored, got %v", minio.users["test-user"].policies)
+	}
+}
+
+func TestUserReconcileMaintenanceMode(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	t.Setenv(envMaintenanceMode, "true")
+	minio.users["test-user"].policies = []string{"writeonly"}
+	setUserCalls := minio.calls["SetUser"]
+
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	paused := meta.FindStatusCondition(cr.Status.Conditions, conditionPaused)
+	if paused == nil || paused.Reason != reasonMaintenanceMode {
+		t.Fatalf("unexpected Paused condition %+v", paused)
+	}
+	drifted := meta.FindStatusCondition(cr.Status.Conditions, conditionDrifted)
+	if drifted == nil || drifted.Message != "policies writeonly are attached but not in the spec; policies readonly are not attached" {
+		t.Fatalf("unexpected Drifted condition %+v", drifted)
+	}
+	if minio.calls["SetUser"] != setUserCalls || !slices.Equal(minio.users["test-user"].policies, []string{"writeonly"}) {
+		t.Fatal("expected user not to be written in maintenance mode")
+	}
+}
+
+func TestUserReconcileMaintenanceConfigMap(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	configMap := &corev1.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: defaultMaintenanceConfigMap, Namespace: defaultMaintenanceNamespace},
+		Data:       map[string]string{maintenanceModeKey: "true"},
+	}
+	r, minio := newTestUserReconciler(t, cr, configMap)
+
+	// Switched on without restarting the operator
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	paused := meta.FindStatusCondition(cr.Status.Conditions, conditionPaused)
+	if paused == nil || paused.Reason != reasonMaintenanceMode {
+		t.Fatalf("unexpected Paused condition %+v", paused)
+	}
+	if _, found := minio.users["test-user"]; found {
+		t.Fatal("expected user not to be created in maintenance mode")
+	}
+
+	// And off again
+	configMap.Data[maintenanceModeKey] = "false"
+	if err := r.Update(context.Background(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, cr)
+	if meta.FindStatusCondition(cr.Status.Conditions, conditionPaused) != nil {
+		t.Fatalf("expected Paused condition to be removed, got %+v", cr.Status.Conditions)
+	}
+	if _, found := minio.users["test-user"]; !found {
+		t.Fatal("expected user to be created once maintenance mode was switched off")
+	}
+
+	// Invalid values are not taken as off
+	configMap.Data[maintenanceModeKey] = "maybe"
+	if err := r.Update(context.Background(), configMap); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, true)
+}
+
+func TestUserReconcileDisabled(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.AccountStatus = "disabled"
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	user := minio.users["test-user"]
+	if user.status != madmin.AccountDisabled {
+		t.Fatal("expected user to be created disabled")
+	}
+	if !slices.Equal(user.policies, []string{"readonly"}) {
+		t.Fatalf("expected policies to be attached to disabled user, got %v", user.policies)
+	}
+
+	// Policies changed out of band are restored while disabled
+	user.policies = []string{"writeonly"}
+	reconcileOnce(t, r, cr, false)
+	if !slices.Equal(user.policies, []string{"readonly"}) {
+		t.Fatalf("expected policies to be restored, got %v", user.policies)
+	}
+}
+
+func TestUserReconcileToggleAccountStatus(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.AccountStatus = "disabled"
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	setUserCalls := minio.calls["SetUser"]
+
+	refetch(t, r.Client, cr)
+	cr.Spec.AccountStatus = "enabled"
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	user := minio.users["test-user"]
+	if user.status != madmin.AccountEnabled {
+		t.Fatal("expected user to be enabled")
+	}
+	if minio.calls["SetUser"] != setUserCalls || minio.calls["SetUserStatus"] != 1 {
+		t.Fatalf("expected only the account status to be set, got %v", minio.calls)
+	}
+	if !slices.Equal(user.policies, []string{"readonly"}) {
+		t.Fatalf("unexpected policies %v", user.policies)
+	}
+}
+
+func TestUserReconcileSpecChange(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	cr.Spec.SecretKey = "new-secret-key"
+	cr.Spec.Policies = []string{"readonly", "writeonly"}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	user := minio.users["test-user"]
+	if user.secretKey != "new-secret-key" {
+		t.Fatal("expected secret key to be updated")
+	}
+	if !slices.Equal(user.policies, []string{"readonly", "writeonly"}) {
+		t.Fatalf("unexpected policies %v", user.policies)
+	}
+}
+
+func TestUserReconcileCredentialsUnchanged(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	setUserCalls := minio.calls["SetUser"]
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	if minio.calls["SetUser"] != setUserCalls {
+		t.Fatalf("expected user not to be set again, got %d calls", minio.calls["SetUser"]-setUserCalls)
+	}
+}
+
+func TestUserReconcileCredentialsRejected(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	// Secret key changed out of band
+	minio.users["test-user"].secretKey = "other-secret-key"
+
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].secretKey != "test-secret-key" {
+		t.Fatal("expected secret key to be restored")
+	}
+}
+
+func TestUserReconcileSecretKeyRef(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.SecretKey = ""
+	cr.Spec.SecretKeyRef = &corev1.SecretKeySelector{
+		LocalObjectReference: corev1.LocalObjectReference{Name: "test-user-secret"},
+		Key:                  "secretKey",
+	}
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	secret := &corev1.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "test-user-secret", Namespace: "default"},
+		Data:       map[string][]byte{"secretKey": []byte("referenced-secret-key")},
+	}
+	if err := r.Create(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].secretKey != "referenced-secret-key" {
+		t.Fatal("expected secret key to be read from the secret")
+	}
+
+	requests := r.usersForSecret(secret)
+	if len(requests) != 1 || requests[0].Name != "test-user" {
+		t.Fatalf("unexpected requests %v", requests)
+	}
+
+	secret.Data["secretKey"] = []byte("rotated-secret-key")
+	if err := r.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].secretKey != "rotated-secret-key" {
+		t.Fatal("expected secret key to be updated from the secret")
+	}
+}
+
+func TestUserReconcileRotation(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.SecretKey = ""
+	cr.Spec.Rotation = &operatorv1.UserRotation{
+		Interval:    metav1.Duration{Duration: time.Hour},
+		GracePeriod: &metav1.Duration{Duration: 10 * time.Minute},
+	}
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Containers: []corev1.Container{{
+				Name:    "app",
+				EnvFrom: []corev1.EnvFromSource{{SecretRef: &corev1.SecretEnvSource{LocalObjectReference: corev1.LocalObjectReference{Name: "test-user-credentials"}}}},
+			}},
+		}}},
+	}
+	if err := r.Create(context.Background(), deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	secret := &corev1.Secret{}
+	getSecret := func() {
+		t.Helper()
+		if err := r.Get(context.Background(), client.ObjectKey{Name: "test-user-credentials", Namespace: "default"}, secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	getSecret()
+	firstKey := string(secret.Data[secretSecretAccessKeyKey])
+	if firstKey == "" || minio.users["test-user"].secretKey != firstKey {
+		t.Fatal("expected generated secret key to be set")
+	}
+
+	result := reconcileOnce(t, r, cr, false)
+	if result.RequeueAfter <= 0 || result.RequeueAfter > time.Hour {
+		t.Fatalf("expected requeue before the next rotation, got %v", result.RequeueAfter)
+	}
+
+	// The interval has passed
+	secret.Annotations[rotatedAtAnnotation] = time.Now().Add(-2 * time.Hour).UTC().Format(time.RFC3339)
+	if err := r.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	getSecret()
+	secondKey := string(secret.Data[secretSecretAccessKeyKey])
+	if secondKey == firstKey || string(secret.Data[secretPreviousSecretAccessKeyKey]) != firstKey {
+		t.Fatal("expected secret key to be rotated, keeping the previous one")
+	}
+	if err := r.Get(context.Background(), client.ObjectKeyFromObject(deployment), deployment); err != nil {
+		t.Fatal(err)
+	}
+	if deployment.Spec.Template.Annotations[credentialsRotatedAtAnnotation] == "" {
+		t.Fatal("expected deployment to be restarted")
+	}
+	if requests := r.usersForDeployment(deployment); len(requests) != 1 || requests[0].Name != cr.Name {
+		t.Fatalf("expected deployment to enqueue the user, got %v", requests)
+	}
+
+	// MinIO keeps the previous secret key until the deployment rolled out
+	result = reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].secretKey != firstKey {
+		t.Fatal("expected previous secret key to be kept in MinIO during the rollout")
+	}
+	if result.RequeueAfter <= 0 || result.RequeueAfter > 10*time.Minute {
+		t.Fatalf("expected requeue before the end of the grace period, got %v", result.RequeueAfter)
+	}
+
+	deployment.Status = appsv1.DeploymentStatus{Replicas: 1, UpdatedReplicas: 1, AvailableReplicas: 1}
+	if err := r.Status().Update(context.Background(), deployment); err != nil {
+		t.Fatal(err)
+	}
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].secretKey != secondKey {
+		t.Fatal("expected rotated secret key to be set once the deployment rolled out")
+	}
+
+	// The grace period has passed
+	getSecret()
+	secret.Annotations[rotatedAtAnnotation] = time.Now().Add(-20 * time.Minute).UTC().Format(time.RFC3339)
+	if err := r.Update(context.Background(), secret); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	getSecret()
+	if _, found := secret.Data[secretPreviousSecretAccessKeyKey]; found {
+		t.Fatal("expected previous secret key to be removed")
+	}
+	if string(secret.Data[secretSecretAccessKeyKey]) != secondKey {
+		t.Fatal("expected secret key not to be rotated again")
+	}
+}
+
+func TestUserReconcileRotationGracePeriodEnded(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.SecretKey = ""
+	cr.Spec.Rotation = &operatorv1.UserRotation{
+		Interval:    metav1.Duration{Duration: time.Hour},
+		GracePeriod: &metav1.Duration{Duration: 10 * time.Minute},
+	}
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	deployment := &appsv1.Deployment{
+		ObjectMeta: metav1.ObjectMeta{Name: "app", Namespace: "default"},
+		Spec: appsv1.DeploymentSpec{Template: corev1.PodTemplateSpec{Spec: corev1.PodSpec{
+			Volumes: []corev1.Volume{{
+				Name:         "credentials",
+				VolumeSource: corev1.VolumeSource{Secret: &corev1.SecretVolumeSource{SecretName: "test-user-credentials"}},
+			}},
+		}}},
+	}
+	if err := r.Create(context.Background(), deployment); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+
+	// Rotate, the deployment never rolls out
+	secret := &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: "test-user-credentials", Namespace: "default"}}
+	setRotatedAt := func(ago time.Duration) {
+		t.Helper()
+		refetch(t, r.Client, secret)
+		secret.Annotations[rotatedAtAnnotation] = time.Now().Add(-ago).UTC().Format(time.RFC3339)
+		if err := r.Update(context.Background(), secret); err != nil {
+			t.Fatal(err)
+		}
+	}
+	setRotatedAt(2 * time.Hour)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, secret)
+	if minio.users["test-user"].secretKey == string(secret.Data[secretSecretAccessKeyKey]) {
+		t.Fatal("expected rotated secret key to wait for the rollout")
+	}
+
+	// The wait ends with the grace period
+	setRotatedAt(20 * time.Minute)
+	reconcileOnce(t, r, cr, false)
+	refetch(t, r.Client, secret)
+	if minio.users["test-user"].secretKey != string(secret.Data[secretSecretAccessKeyKey]) {
+		t.Fatal("expected rotated secret key to be set after the grace period")
+	}
+}
+
+func TestUserReconcileExpired(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	cr.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-time.Minute)}
+	if err := r.Update(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+	setUserCalls := minio.calls["SetUser"]
+
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].status != madmin.AccountDisabled {
+		t.Fatal("expected expired user to be disabled")
+	}
+	if minio.calls["SetUserStatus"] != 1 || minio.calls["SetUser"] != setUserCalls {
+		t.Fatalf("expected only the account status to be set, got %v", minio.calls)
+	}
+	refetch(t, r.Client, cr)
+	if cr.Status.RemainingLifetime != lifetimeExpired {
+		t.Fatalf("unexpected remaining lifetime %q", cr.Status.RemainingLifetime)
+	}
+
+	// Expired users are not enabled again
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].status != madmin.AccountDisabled {
+		t.Fatal("expected expired user to stay disabled")
+	}
+}
+
+func TestUserReconcileDeleteAfterExpiry(t *testing.T) {
+	cr := newTestUser("readonly")
+	cr.Spec.ExpiresAt = &metav1.Time{Time: time.Now().Add(-2 * time.Hour)}
+	cr.Spec.DeleteAfterExpiry = &metav1.Duration{Duration: time.Hour}
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	if minio.users["test-user"].status != madmin.AccountDisabled {
+		t.Fatal("expected expired user to be created disabled")
+	}
+
+	reconcileOnce(t, r, cr, false)
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.users["test-user"]; found {
+		t.Fatal("expected user to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.User{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestUserReconcileDelete(t *testing.T) {
+	cr := newTestUser()
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	if _, found := minio.users["test-user"]; found {
+		t.Fatal("expected user to be removed")
+	}
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.User{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
+
+func TestUserReconcileDeleteMissingUser(t *testing.T) {
+	cr := newTestUser()
+	cr.Status.State = typeCreating
+	r, minio := newTestUserReconciler(t, cr)
+
+	reconcileOnce(t, r, cr, false)
+	delete(minio.users, "test-user")
+
+	refetch(t, r.Client, cr)
+	if err := r.Delete(context.Background(), cr); err != nil {
+		t.Fatal(err)
+	}
+
+	reconcileOnce(t, r, cr, false)
+	err := r.Get(context.Background(), client.ObjectKeyFromObject(cr), &operatorv1.User{})
+	if !apierrors.IsNotFound(err) {
+		t.Fatalf("expected resource to be deleted, got %v", err)
+	}
+}
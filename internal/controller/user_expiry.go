@@ -0,0 +1,72 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"time"
+
+	"k8s.io/apimachinery/pkg/util/duration"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Remaining lifetime of expired users
+const lifetimeExpired = "Expired"
+
+// How often the remaining lifetime of a user is refreshed in its status
+const lifetimeRefreshInterval = time.Hour
+
+func isUserExpired(cr *operatorv1.User) bool {
+	return cr.Spec.ExpiresAt != nil && !time.Now().Before(cr.Spec.ExpiresAt.Time)
+}
+
+// Account status the user should have in MinIO, disabled once it expires
+func accountStatus(cr *operatorv1.User) string {
+	if isUserExpired(cr) {
+		return "disabled"
+	}
+	return cr.Spec.AccountStatus
+}
+
+// Time at which an expired user is deleted, if it is
+func userDeletionTime(cr *operatorv1.User) (time.Time, bool) {
+	if cr.Spec.ExpiresAt == nil || cr.Spec.DeleteAfterExpiry == nil {
+		return time.Time{}, false
+	}
+	return cr.Spec.ExpiresAt.Add(cr.Spec.DeleteAfterExpiry.Duration), true
+}
+
+func remainingLifetime(cr *operatorv1.User) string {
+	if cr.Spec.ExpiresAt == nil {
+		return ""
+	}
+	if isUserExpired(cr) {
+		return lifetimeExpired
+	}
+	return duration.HumanDuration(time.Until(cr.Spec.ExpiresAt.Time))
+}
+
+// Time until the remaining lifetime is refreshed, the user expires or is deleted
+func expiryRequeueAfter(cr *operatorv1.User) time.Duration {
+	if cr.Spec.ExpiresAt == nil {
+		return 0
+	}
+
+	if !isUserExpired(cr) {
+		return min(time.Until(cr.Spec.ExpiresAt.Time), lifetimeRefreshInterval)
+	}
+	if deleteAt, found := userDeletionTime(cr); found {
+		return max(time.Until(deleteAt), time.Second)
+	}
+	return 0
+}
+
+// Shortest of two requeue delays, where zero means no requeue
+func minRequeueAfter(a time.Duration, b time.Duration) time.Duration {
+	if a == 0 || (b != 0 && b < a) {
+		return b
+	}
+	return a
+}
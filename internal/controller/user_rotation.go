@@ -0,0 +1,284 @@
+// SPDX-FileCopyrightText: © 2025 DSLab - Fondazione Bruno Kessler
+//
+// SPDX-License-Identifier: AGPL-3.0-or-later
+
+package controller
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	appsv1 "k8s.io/api/apps/v1"
+	corev1 "k8s.io/api/core/v1"
+	apierrors "k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+
+	operatorv1 "github.com/scc-digitalhub/minio-operator/api/v1"
+)
+
+// Key of the credentials Secret holding the previous secret key during the grace period
+const secretPreviousSecretAccessKeyKey = "AWS_SECRET_ACCESS_KEY_PREVIOUS"
+
+// Annotation of the credentials Secret holding the time of the last rotation
+const rotatedAtAnnotation = "minio.scc-digitalhub.github.io/rotated-at"
+
+// Annotation of the pod template of Deployments restarted after a rotation
+const credentialsRotatedAtAnnotation = "minio.scc-digitalhub.github.io/credentials-rotated-at"
+
+func rotationSecretName(cr *operatorv1.User) string {
+	if cr.Spec.Rotation.SecretName != "" {
+		return cr.Spec.Rotation.SecretName
+	}
+	return cr.Name + "-credentials"
+}
+
+func rotationGracePeriod(cr *operatorv1.User) time.Duration {
+	if cr.Spec.Rotation == nil || cr.Spec.Rotation.GracePeriod == nil {
+		return 0
+	}
+	return cr.Spec.Rotation.GracePeriod.Duration
+}
+
+// Generate the secret key of a user in its credentials Secret, rotating it once the interval has
+// passed and restarting the Deployments using it, and record the rotation times in the status.
+// The previous secret key is kept in the Secret until the grace period ends.
+func (r *UserReconciler) reconcileRotation(ctx context.Context, cr *operatorv1.User) error {
+	log := log.FromContext(ctx)
+
+	interval := cr.Spec.Rotation.Interval.Duration
+	if interval <= 0 {
+		return fmt.Errorf("rotation interval must be positive")
+	}
+
+	secretName := rotationSecretName(cr)
+	secret := &corev1.Secret{}
+	err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: cr.Namespace}, secret)
+	if err != nil && !apierrors.IsNotFound(err) {
+		return fmt.Errorf("failed to get secret %s: %w", secretName, err)
+	}
+	found := err == nil
+	if !found {
+		secret = &corev1.Secret{ObjectMeta: metav1.ObjectMeta{Name: secretName, Namespace: cr.Namespace}}
+	} else if !metav1.IsControlledBy(secret, cr) {
+		return fmt.Errorf("secret %s is not owned by user %s", secretName, cr.Name)
+	}
+	if secret.Data == nil {
+		secret.Data = map[string][]byte{}
+	}
+
+	now := time.Now().UTC().Truncate(time.Second)
+	rotatedAt, err := time.Parse(time.RFC3339, secret.Annotations[rotatedAtAnnotation])
+	current := secret.Data[secretSecretAccessKeyKey]
+	rotating := len(current) == 0 || err != nil || !now.Before(rotatedAt.Add(interval))
+
+	changed := false
+	if rotating {
+		secretKey, err := generateSecretKey()
+		if err != nil {
+			return err
+		}
+
+		delete(secret.Data, secretPreviousSecretAccessKeyKey)
+		if len(current) > 0 && rotationGracePeriod(cr) > 0 {
+			secret.Data[secretPreviousSecretAccessKeyKey] = current
+		}
+		secret.Data[secretSecretAccessKeyKey] = []byte(secretKey)
+		metav1.SetMetaDataAnnotation(&secret.ObjectMeta, rotatedAtAnnotation, now.Format(time.RFC3339))
+		rotatedAt = now
+		changed = true
+	} else if _, previous := secret.Data[secretPreviousSecretAccessKeyKey]; previous && !now.Before(rotatedAt.Add(rotationGracePeriod(cr))) {
+		log.Info("Removing previous secret key after the grace period", "secret", secretName)
+		delete(secret.Data, secretPreviousSecretAccessKeyKey)
+		changed = true
+	}
+	if string(secret.Data[secretAccessKeyIDKey]) != cr.Spec.AccessKey {
+		secret.Data[secretAccessKeyIDKey] = []byte(cr.Spec.AccessKey)
+		changed = true
+	}
+
+	if changed {
+		if err := controllerutil.SetControllerReference(cr, secret, r.Scheme); err != nil {
+			return err
+		}
+		if found {
+			err = r.Update(ctx, secret)
+		} else {
+			err = r.Create(ctx, secret)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to write secret %s: %w", secretName, err)
+		}
+	}
+
+	if rotating && len(current) > 0 {
+		log.Info("Rotated secret key", "secret", secretName)
+		r.Recorder.Event(cr, "Normal", "CredentialsRotated",
+			fmt.Sprintf("Secret key rotated in Secret %s", secretName))
+
+		// Applications pick up the rotated secret key once restarted
+		if err := r.restartDeployments(ctx, cr); err != nil {
+			log.Error(err, "Failed to restart deployments")
+			r.Recorder.Event(cr, "Warning", "RestartFailed", err.Error())
+		}
+	}
+
+	lastRotation := metav1.NewTime(rotatedAt)
+	nextRotation := metav1.NewTime(rotatedAt.Add(interval))
+	if !lastRotation.Equal(cr.Status.LastRotationTime) || !nextRotation.Equal(cr.Status.NextRotationTime) {
+		cr.Status.LastRotationTime = &lastRotation
+		cr.Status.NextRotationTime = &nextRotation
+		if err := r.Status().Update(ctx, cr); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// Read the generated secret key of a user from its credentials Secret
+func (r *UserReconciler) rotatedSecretKey(ctx context.Context, cr *operatorv1.User) (string, error) {
+	secretName := rotationSecretName(cr)
+	secret := &corev1.Secret{}
+	if err := r.Get(ctx, types.NamespacedName{Name: secretName, Namespace: cr.Namespace}, secret); err != nil {
+		return "", fmt.Errorf("failed to get secret %s: %w", secretName, err)
+	}
+	if !metav1.IsControlledBy(secret, cr) {
+		return "", fmt.Errorf("secret %s is not owned by user %s", secretName, cr.Name)
+	}
+	secretKey := string(secret.Data[secretSecretAccessKeyKey])
+	if secretKey == "" {
+		return "", fmt.Errorf("secret %s has no %s key", secretName, secretSecretAccessKeyKey)
+	}
+
+	return secretKey, nil
+}
+
+// End of the grace period of the last rotation, zero if there is none or it has ended
+func rotationGraceEnd(cr *operatorv1.User) time.Time {
+	grace := rotationGracePeriod(cr)
+	if grace <= 0 || cr.Status.LastRotationTime == nil {
+		return time.Time{}
+	}
+
+	graceEnd := cr.Status.LastRotationTime.Add(grace)
+	if !graceEnd.After(time.Now()) {
+		return time.Time{}
+	}
+	return graceEnd
+}
+
+// Time until the next rotation, or until the previous secret key is removed if sooner
+func rotationRequeueAfter(cr *operatorv1.User) time.Duration {
+	if cr.Spec.Rotation == nil || cr.Status.NextRotationTime == nil {
+		return 0
+	}
+
+	next := cr.Status.NextRotationTime.Time
+	if graceEnd := rotationGraceEnd(cr); !graceEnd.IsZero() && graceEnd.Before(next) {
+		next = graceEnd
+	}
+
+	return max(time.Until(next), time.Second)
+}
+
+// Check whether a rotated secret key is waiting for the Deployments using it to roll out before
+// being set in MinIO, so that the pods still running with the previous one keep working. The
+// wait ends with the grace period.
+func (r *UserReconciler) rolloutPending(ctx context.Context, cr *operatorv1.User) (bool, error) {
+	if rotationGraceEnd(cr).IsZero() {
+		return false, nil
+	}
+
+	secretName := rotationSecretName(cr)
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(cr.Namespace)); err != nil {
+		return false, err
+	}
+
+	for _, deployment := range deployments.Items {
+		if podUsesSecret(&deployment.Spec.Template.Spec, secretName) && !deploymentRolledOut(&deployment) {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+// Check whether all the replicas of a Deployment run its current pod template
+func deploymentRolledOut(deployment *appsv1.Deployment) bool {
+	replicas := int32(1)
+	if deployment.Spec.Replicas != nil {
+		replicas = *deployment.Spec.Replicas
+	}
+
+	status := deployment.Status
+	return status.ObservedGeneration >= deployment.Generation &&
+		status.UpdatedReplicas == replicas &&
+		status.Replicas == status.UpdatedReplicas &&
+		status.AvailableReplicas == status.UpdatedReplicas
+}
+
+// Trigger a rollout of the Deployments using the credentials Secret of a rotated user
+func (r *UserReconciler) restartDeployments(ctx context.Context, cr *operatorv1.User) error {
+	log := log.FromContext(ctx)
+
+	secretName := rotationSecretName(cr)
+	deployments := &appsv1.DeploymentList{}
+	if err := r.List(ctx, deployments, client.InNamespace(cr.Namespace)); err != nil {
+		return err
+	}
+
+	rotatedAt := time.Now().UTC().Format(time.RFC3339)
+	for i := range deployments.Items {
+		deployment := &deployments.Items[i]
+		if !podUsesSecret(&deployment.Spec.Template.Spec, secretName) {
+			continue
+		}
+
+		patch := client.MergeFrom(deployment.DeepCopy())
+		metav1.SetMetaDataAnnotation(&deployment.Spec.Template.ObjectMeta, credentialsRotatedAtAnnotation, rotatedAt)
+		if err := r.Patch(ctx, deployment, patch); err != nil {
+			return fmt.Errorf("failed to restart deployment %s: %w", deployment.Name, err)
+		}
+		log.Info("Restarted deployment after rotation", "deployment", deployment.Name)
+	}
+
+	return nil
+}
+
+// Check whether a pod mounts a Secret or reads it into its environment
+func podUsesSecret(spec *corev1.PodSpec, secretName string) bool {
+	for _, volume := range spec.Volumes {
+		if volume.Secret != nil && volume.Secret.SecretName == secretName {
+			return true
+		}
+		if volume.Projected != nil {
+			for _, source := range volume.Projected.Sources {
+				if source.Secret != nil && source.Secret.Name == secretName {
+					return true
+				}
+			}
+		}
+	}
+
+	containers := append(append([]corev1.Container{}, spec.InitContainers...), spec.Containers...)
+	for _, container := range containers {
+		for _, env := range container.EnvFrom {
+			if env.SecretRef != nil && env.SecretRef.Name == secretName {
+				return true
+			}
+		}
+		for _, env := range container.Env {
+			if env.ValueFrom != nil && env.ValueFrom.SecretKeyRef != nil && env.ValueFrom.SecretKeyRef.Name == secretName {
+				return true
+			}
+		}
+	}
+
+	return false
+}